@@ -1,6 +1,10 @@
 package main
 
 import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -9,8 +13,15 @@ import (
 
 	"github.com/bverschueren/check_mk_exporter/collector"
 	"github.com/bverschueren/check_mk_exporter/config"
+	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
+)
+
+const (
+	// the maximum size of pushed agent output
+	maxPushSize = 64 << 20
 )
 
 var (
@@ -25,19 +36,41 @@ var (
 		"listen.port",
 		"Port to listen on",
 	).Default("2112").Int()
+	inventoryEndpoint = kingpin.Flag(
+		"web.inventory",
+		"Serve the inventory of a target as JSON on /inventory",
+	).Bool()
+	tlsCertFile = kingpin.Flag(
+		"web.tls-cert-file",
+		"Certificate to serve HTTPS with",
+	).String()
+	tlsKeyFile = kingpin.Flag(
+		"web.tls-key-file",
+		"Key of the certificate to serve HTTPS with",
+	).String()
+	tlsClientCAFile = kingpin.Flag(
+		"web.tls-client-ca-file",
+		"CA of the client certificates authenticating pushing targets",
+	).String()
+	logLevel = kingpin.Flag(
+		"log.level",
+		"Enable specify log level",
+	).Short('l').String()
 )
 
-func CheckMkHandler(w http.ResponseWriter, r *http.Request) {
+// targetFromRequest looks up the target of the request, applying the
+// connection details overrides passed as parameters.
+func targetFromRequest(w http.ResponseWriter, r *http.Request) (config.Target, bool) {
 	targetHost := r.URL.Query().Get("target")
 	if targetHost == "" {
 		http.Error(w, "'target' parameter must be specified", 400)
-		return
+		return config.Target{}, false
 	}
 	target, ok := targets[targetHost]
 	if !ok {
 		http.Error(w, fmt.Sprintf("Unknown target '%s'", targetHost), 400)
 		log.Errorf("Unknown target '%s'", targetHost)
-		return
+		return config.Target{}, false
 	}
 
 	// connection details overrides
@@ -58,22 +91,126 @@ func CheckMkHandler(w http.ResponseWriter, r *http.Request) {
 	if targetIdentityFile != "" {
 		target.IdentityFile = targetIdentityFile
 	}
+	return target, true
+}
+
+func CheckMkHandler(w http.ResponseWriter, r *http.Request) {
+	target, ok := targetFromRequest(w, r)
+	if !ok {
+		return
+	}
 
-	collector, _ := collector.NewMKCheckCollector(target)
 	registry := prometheus.NewRegistry()
-	registry.MustRegister(collector)
+	if target.PollInterval > 0 {
+		cached, ok := collector.NewCachedCollector(target)
+		if !ok {
+			http.Error(w, fmt.Sprintf("Target '%s' wasn't polled yet", target.Name), 503)
+			return
+		}
+		registry.MustRegister(cached)
+	} else {
+		collector, _ := collector.NewMKCheckCollector(target)
+		registry.MustRegister(collector)
+	}
 	handler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
 	handler.ServeHTTP(w, r)
 }
 
-func init() {
-	logLevel := kingpin.Flag(
-		"log.level",
-		"Enable specify log level",
-	).Short('l').String()
+func InventoryHandler(w http.ResponseWriter, r *http.Request) {
+	target, ok := targetFromRequest(w, r)
+	if !ok {
+		return
+	}
 
-	kingpin.Parse()
+	var inventory *collector.Inventory
+	if target.PollInterval > 0 {
+		// running the agent again would consume the logwatch lines of the next poll
+		if inventory, ok = collector.PolledInventory(target); !ok {
+			http.Error(w, fmt.Sprintf("Target '%s' wasn't polled yet", target.Name), 503)
+			return
+		}
+	} else {
+		mc, _ := collector.NewMKCheckCollector(target)
+		var err error
+		if inventory, err = mc.Inventory(); err != nil {
+			http.Error(w, fmt.Sprintf("Unable to collect inventory from '%s': %s", target.HostName, err), 502)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(inventory); err != nil {
+		log.Errorf("Unable to write inventory: %s", err)
+	}
+}
+
+// PushHandler stores the agent output posted to /push/<target> by targets
+// with the 'push' transport.
+func PushHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Agent output must be posted", 405)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/push/")
+	// unknown targets are refused like unauthorized pushes, not to reveal
+	// which targets exist
+	target, ok := targets[name]
+	if !ok || target.Transport != "push" || !pushAuthorized(r, target) {
+		http.Error(w, "Unauthorized", 401)
+		log.Warnf("Unauthorized push for '%s' from %s", name, r.RemoteAddr)
+		return
+	}
+
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPushSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unable to read agent output: %s", err), 400)
+		return
+	}
+	if err := collector.StorePushedOutput(target, data); err != nil {
+		http.Error(w, fmt.Sprintf("Unable to store agent output: %s", err), 500)
+		log.Errorf("Unable to store output pushed by '%s': %s", name, err)
+		return
+	}
+	w.WriteHeader(204)
+}
 
+// pushAuthorized accepts a verified client certificate issued for the
+// target's name or the target's token as bearer token.
+func pushAuthorized(r *http.Request, target config.Target) bool {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		if r.TLS.VerifiedChains[0][0].Subject.CommonName == target.Name {
+			return true
+		}
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return target.PushToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(target.PushToken)) == 1
+}
+
+// listenAndServe serves HTTPS if a certificate is configured, verifying
+// client certificates if given.
+func listenAndServe(addr string) error {
+	if *tlsCertFile == "" {
+		return http.ListenAndServe(addr, nil)
+	}
+	server := &http.Server{Addr: addr}
+	if *tlsClientCAFile != "" {
+		ca, err := ioutil.ReadFile(*tlsClientCAFile)
+		if err != nil {
+			return err
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(ca) {
+			return fmt.Errorf("no certificates found in '%s'", *tlsClientCAFile)
+		}
+		server.TLSConfig = &tls.Config{
+			ClientCAs:  clientCAs,
+			ClientAuth: tls.VerifyClientCertIfGiven,
+		}
+	}
+	return server.ListenAndServeTLS(*tlsCertFile, *tlsKeyFile)
+}
+
+func setLogLevel() {
 	switch *logLevel {
 	case "debug":
 		log.SetLevel(log.DebugLevel)
@@ -88,10 +225,25 @@ func init() {
 
 func main() {
 	kingpin.Parse()
+	setLogLevel()
 
 	cfg.ReadFile(&targets)
+	if err := collector.SetLogwatchRules(cfg.Logwatch.Rules); err != nil {
+		log.Fatalf("Invalid logwatch configuration: %s", err)
+	}
+	if err := collector.SetFileinfoGroups(cfg.Fileinfo.Groups); err != nil {
+		log.Fatalf("Invalid fileinfo configuration: %s", err)
+	}
+	if err := collector.SetHostLabelsMode(cfg.HostLabels.Mode); err != nil {
+		log.Fatalf("Invalid host labels configuration: %s", err)
+	}
+	collector.StartPolling(targets, cfg.Polling.Concurrency, cfg.Polling.Jitter)
 	http.Handle("/metrics", prometheus.Handler())
 	http.HandleFunc("/check_mk", CheckMkHandler)
+	http.HandleFunc("/push/", PushHandler)
+	if *inventoryEndpoint {
+		http.HandleFunc("/inventory", InventoryHandler)
+	}
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
              <head><title>Check_MK Exporter</title></head>
@@ -102,5 +254,5 @@ func main() {
              </html>`))
 	})
 	log.Infof("Start listening on :%d", *listenPort)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", *listenPort), nil))
+	log.Fatal(listenAndServe(fmt.Sprintf(":%d", *listenPort)))
 }
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"github.com/bverschueren/check_mk_exporter/collector"
+	"github.com/bverschueren/check_mk_exporter/config"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCheckMkHandler(t *testing.T) {
@@ -23,3 +27,61 @@ func TestCheckMkHandler(t *testing.T) {
 			status, http.StatusOK, rr.Body.String())
 	}
 }
+
+func TestPushHandler(t *testing.T) {
+	targets["pushing"] = config.Target{Name: "pushing", Transport: "push", PushToken: "t0ken"}
+	targets["pulled"] = config.Target{Name: "pulled", PushToken: "t0ken"}
+	defer delete(targets, "pushing")
+	defer delete(targets, "pulled")
+	defer collector.DropPushedOutput("pushing")
+
+	for _, tc := range []struct {
+		method, path, token string
+		status              int
+	}{
+		{"POST", "/push/pushing", "t0ken", http.StatusNoContent},
+		{"POST", "/push/pushing", "wrong", http.StatusUnauthorized},
+		{"POST", "/push/pushing", "", http.StatusUnauthorized},
+		{"GET", "/push/pushing", "t0ken", http.StatusMethodNotAllowed},
+		{"POST", "/push/pulled", "t0ken", http.StatusUnauthorized},
+		{"POST", "/push/unknown", "t0ken", http.StatusUnauthorized},
+		{"POST", "/push/unknown", "", http.StatusUnauthorized},
+	} {
+		req, err := http.NewRequest(tc.method, tc.path, strings.NewReader("<<<check_mk>>>\nVersion: 2.0.0\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(PushHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != tc.status {
+			t.Errorf("%s %s with token '%s' returned status code %d, want %d", tc.method, tc.path, tc.token, status, tc.status)
+		}
+	}
+}
+
+func TestInventoryHandlerPolled(t *testing.T) {
+	targets["polled"] = config.Target{
+		Name:         "polled",
+		Transport:    "exec",
+		Command:      "cat testdata/check_mk testdata/inventory",
+		PollInterval: time.Hour,
+	}
+	defer delete(targets, "polled")
+
+	req, err := http.NewRequest("GET", "/inventory?target=polled", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(InventoryHandler).ServeHTTP(rr, req)
+
+	// polled targets are served from their last poll, not by running the agent
+	if status := rr.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v. Body:\n%s",
+			status, http.StatusServiceUnavailable, rr.Body.String())
+	}
+}
@@ -0,0 +1,185 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+var (
+	apacheLabelNames      = []string{"address", "port", "instance"}
+	apacheStateLabelNames = []string{"address", "port", "instance", "state"}
+
+	// the server-status?auto keys exported, all other keys are ignored
+	apacheKeys = []string{"Total Accesses", "Total kBytes", "Uptime", "BusyWorkers", "IdleWorkers", "Scoreboard"}
+
+	apacheScoreboardStates = map[rune]string{
+		'_': "waiting",
+		'S': "starting",
+		'R': "reading",
+		'W': "sending",
+		'K': "keepalive",
+		'D': "dns",
+		'C': "closing",
+		'L': "logging",
+		'G': "finishing",
+		'I': "idle_cleanup",
+		'.': "open",
+	}
+)
+
+type apacheCollector struct {
+	RequestsDesc   *prometheus.Desc
+	SentBytesDesc  *prometheus.Desc
+	UptimeDesc     *prometheus.Desc
+	WorkersDesc    *prometheus.Desc
+	ScoreboardDesc *prometheus.Desc
+}
+
+type apacheServer struct {
+	address, port, instance string
+}
+
+type apacheStats struct {
+	server        apacheServer
+	values        map[string]float64
+	scoreboard    map[string]float64
+	hasScoreboard bool
+}
+
+func init() {
+	registerCollector("apache_status", NewApacheCollector)
+}
+
+func NewApacheCollector() (Collector, error) {
+	subsystem := "apache"
+
+	RequestsDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "requests_total"),
+		"Number of requests handled by the server",
+		apacheLabelNames, nil,
+	)
+	SentBytesDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "sent_bytes_total"),
+		"Number of bytes sent by the server",
+		apacheLabelNames, nil,
+	)
+	UptimeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "uptime_seconds"),
+		"Time since the server was started",
+		apacheLabelNames, nil,
+	)
+	WorkersDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "workers"),
+		"Number of busy and idle workers",
+		apacheStateLabelNames, nil,
+	)
+	ScoreboardDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "scoreboard"),
+		"Number of scoreboard slots by state",
+		apacheStateLabelNames, nil,
+	)
+	return apacheCollector{
+		RequestsDesc:   RequestsDesc,
+		SentBytesDesc:  SentBytesDesc,
+		UptimeDesc:     UptimeDesc,
+		WorkersDesc:    WorkersDesc,
+		ScoreboardDesc: ScoreboardDesc,
+	}, nil
+}
+
+func (a apacheCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	stats := a.parseStats(unparsedStats)
+
+	for _, s := range stats {
+		labels := []string{s.server.address, s.server.port, s.server.instance}
+		if value, ok := s.values["Total Accesses"]; ok {
+			ch <- prometheus.MustNewConstMetric(a.RequestsDesc, prometheus.CounterValue, value, labels...)
+		}
+		if value, ok := s.values["Total kBytes"]; ok {
+			ch <- prometheus.MustNewConstMetric(a.SentBytesDesc, prometheus.CounterValue, value*1024, labels...)
+		}
+		if value, ok := s.values["Uptime"]; ok {
+			ch <- prometheus.MustNewConstMetric(a.UptimeDesc, prometheus.GaugeValue, value, labels...)
+		}
+		for key, state := range map[string]string{"BusyWorkers": "busy", "IdleWorkers": "idle"} {
+			if value, ok := s.values[key]; ok {
+				ch <- prometheus.MustNewConstMetric(
+					a.WorkersDesc, prometheus.GaugeValue, value, append(labels, state)...,
+				)
+			}
+		}
+		if !s.hasScoreboard {
+			continue
+		}
+		for _, state := range apacheScoreboardStates {
+			ch <- prometheus.MustNewConstMetric(
+				a.ScoreboardDesc, prometheus.GaugeValue, s.scoreboard[state], append(labels, state)...,
+			)
+		}
+	}
+	return nil
+}
+
+// parseStats handles the server-status?auto output per server, prefixed by
+// its address, port and, for newer plugins, the instance name, which tells
+// apart virtual hosts sharing an address and port:
+//
+//	127.0.0.1 80 Total Accesses: 2421
+//	127.0.0.1 443 www Scoreboard: __W_K.....
+func (a apacheCollector) parseStats(unparsedStats *[]string) []apacheStats {
+
+	stats := []apacheStats{}
+	index := make(map[apacheServer]int)
+
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		// IPv6 addresses contain colons as well, so look for the key itself
+		key, prefix, value := "", "", ""
+		for _, k := range apacheKeys {
+			if i := strings.Index(stat, " "+k+":"); i >= 0 {
+				key, prefix, value = k, stat[:i], strings.TrimSpace(stat[i+len(k)+2:])
+				break
+			}
+		}
+		fields := strings.Fields(prefix)
+		if key == "" || len(fields) < 2 {
+			continue
+		}
+
+		server := apacheServer{address: fields[0], port: fields[1]}
+		if len(fields) > 2 {
+			server.instance = fields[2]
+		}
+		if _, ok := index[server]; !ok {
+			index[server] = len(stats)
+			stats = append(stats, apacheStats{
+				server:     server,
+				values:     make(map[string]float64),
+				scoreboard: make(map[string]float64),
+			})
+		}
+		s := &stats[index[server]]
+
+		if key == "Scoreboard" {
+			if s.hasScoreboard {
+				continue
+			}
+			s.hasScoreboard = true
+			for _, slot := range value {
+				if state, ok := apacheScoreboardStates[slot]; ok {
+					s.scoreboard[state]++
+				}
+			}
+			continue
+		}
+		if _, ok := s.values[key]; ok {
+			continue
+		}
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			s.values[key] = v
+		}
+	}
+	return stats
+}
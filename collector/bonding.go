@@ -0,0 +1,216 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strings"
+)
+
+var (
+	bondLabelNames      = []string{"bond"}
+	bondInfoLabelNames  = []string{"bond", "mode"}
+	bondSlaveLabelNames = []string{"bond", "slave"}
+
+	ovsActiveSlave = regexp.MustCompile(`\(([^)]+)\)$`)
+)
+
+// bondingCollector exports the Linux kernel and Open vSwitch bonding
+// sections as a single check_mk_bond_* metric family.
+type bondingCollector struct {
+	source              string
+	UpDesc              *prometheus.Desc
+	InfoDesc            *prometheus.Desc
+	SlaveUpDesc         *prometheus.Desc
+	ActiveSlaveInfoDesc *prometheus.Desc
+}
+
+type bondSlave struct {
+	name string
+	up   bool
+}
+
+type bondStats struct {
+	name, mode, activeSlave string
+	up, hasStatus           bool
+	slaves                  []bondSlave
+}
+
+func init() {
+	registerCollector("lnx_bonding", NewLnxBondingCollector)
+	registerCollector("ovs_bonding", NewOvsBondingCollector)
+}
+
+func NewLnxBondingCollector() (Collector, error) {
+	return newBondingCollector("lnx_bonding"), nil
+}
+
+func NewOvsBondingCollector() (Collector, error) {
+	return newBondingCollector("ovs_bonding"), nil
+}
+
+func newBondingCollector(source string) bondingCollector {
+	subsystem := "bond"
+
+	UpDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "up"),
+		"Whether the bond's MII status is up (1) or not (0)",
+		bondLabelNames, nil,
+	)
+	InfoDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "info"),
+		"Bond mode",
+		bondInfoLabelNames, nil,
+	)
+	SlaveUpDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "slave_up"),
+		"Whether the slave's MII status is up (1) or not (0)",
+		bondSlaveLabelNames, nil,
+	)
+	ActiveSlaveInfoDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "active_slave_info"),
+		"Currently active slave of the bond",
+		bondSlaveLabelNames, nil,
+	)
+	return bondingCollector{
+		source:              source,
+		UpDesc:              UpDesc,
+		InfoDesc:            InfoDesc,
+		SlaveUpDesc:         SlaveUpDesc,
+		ActiveSlaveInfoDesc: ActiveSlaveInfoDesc,
+	}
+}
+
+func (b bondingCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	var bonds []bondStats
+	if b.source == "ovs_bonding" {
+		bonds = parseOvsBondingStats(unparsedStats)
+	} else {
+		bonds = parseLnxBondingStats(unparsedStats)
+	}
+
+	for _, s := range bonds {
+		ch <- prometheus.MustNewConstMetric(
+			b.InfoDesc, prometheus.GaugeValue, 1, s.name, s.mode,
+		)
+		if s.hasStatus {
+			ch <- prometheus.MustNewConstMetric(
+				b.UpDesc, prometheus.GaugeValue, boolToFloat(s.up), s.name,
+			)
+		}
+		if s.activeSlave != "" {
+			ch <- prometheus.MustNewConstMetric(
+				b.ActiveSlaveInfoDesc, prometheus.GaugeValue, 1, s.name, s.activeSlave,
+			)
+		}
+		for _, slave := range s.slaves {
+			ch <- prometheus.MustNewConstMetric(
+				b.SlaveUpDesc, prometheus.GaugeValue, boolToFloat(slave.up), s.name, slave.name,
+			)
+		}
+	}
+	return nil
+}
+
+// parseLnxBondingStats handles the contents of /proc/net/bonding/*, each file
+// introduced by a '==> bond0 <==' line.
+func parseLnxBondingStats(unparsedStats *[]string) []bondStats {
+
+	bonds := []bondStats{}
+	var bond *bondStats
+	var slave *bondSlave
+	flush := func() {
+		if bond == nil {
+			return
+		}
+		if slave != nil {
+			bond.slaves = append(bond.slaves, *slave)
+			slave = nil
+		}
+		bonds = append(bonds, *bond)
+		bond = nil
+	}
+
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		if strings.HasPrefix(stat, "==> ") && strings.HasSuffix(stat, " <==") {
+			flush()
+			name := strings.TrimSuffix(strings.TrimPrefix(stat, "==> "), " <==")
+			bond = &bondStats{name: name[strings.LastIndex(name, "/")+1:]}
+			continue
+		}
+		parts := strings.SplitN(stat, ":", 2)
+		if bond == nil || len(parts) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		switch key {
+		case "Bonding Mode":
+			bond.mode = value
+		case "Currently Active Slave":
+			if value != "None" {
+				bond.activeSlave = value
+			}
+		case "Slave Interface":
+			if slave != nil {
+				bond.slaves = append(bond.slaves, *slave)
+			}
+			slave = &bondSlave{name: value}
+		case "MII Status":
+			if slave != nil {
+				slave.up = value == "up"
+			} else {
+				bond.up = value == "up"
+				bond.hasStatus = true
+			}
+		}
+	}
+	flush()
+	return bonds
+}
+
+// parseOvsBondingStats handles 'ovs-appctl bond/show' output, each bond
+// introduced by a '[bond1]' line. Newer Open vSwitch releases call slaves
+// members.
+func parseOvsBondingStats(unparsedStats *[]string) []bondStats {
+
+	bonds := []bondStats{}
+	var bond *bondStats
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		line := strings.TrimSpace(stat)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			if bond != nil {
+				bonds = append(bonds, *bond)
+			}
+			bond = &bondStats{name: strings.Trim(line, "[]")}
+			continue
+		}
+		if bond == nil {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		key := strings.TrimSpace(parts[0])
+		value := ""
+		if len(parts) == 2 {
+			value = strings.TrimSpace(parts[1])
+		}
+		switch {
+		case key == "bond_mode":
+			bond.mode = value
+		case key == "active slave mac" || key == "active member mac":
+			if match := ovsActiveSlave.FindStringSubmatch(value); match != nil && match[1] != "none" {
+				bond.activeSlave = match[1]
+			}
+		case strings.HasPrefix(key, "slave ") || strings.HasPrefix(key, "member "):
+			bond.slaves = append(bond.slaves, bondSlave{
+				name: strings.Fields(key)[1],
+				up:   value == "enabled",
+			})
+		}
+	}
+	if bond != nil {
+		bonds = append(bonds, *bond)
+	}
+	return bonds
+}
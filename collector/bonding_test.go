@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestLnxBondingParseStats(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/bonding")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+
+	bonds := parseLnxBondingStats(structuredStats["lnx_bonding"])
+	if want, got := 2, len(bonds); want != got {
+		t.Fatalf("want %d bonds, got %d", want, got)
+	}
+
+	bond0 := bonds[0]
+	if want, got := "bond0", bond0.name; want != got {
+		t.Errorf("want bond %s, got %s", want, got)
+	}
+	if want, got := "fault-tolerance (active-backup)", bond0.mode; want != got {
+		t.Errorf("want mode %s, got %s", want, got)
+	}
+	if !bond0.hasStatus || !bond0.up {
+		t.Errorf("want bond0 to be up")
+	}
+	if want, got := "eth0", bond0.activeSlave; want != got {
+		t.Errorf("want active slave %s, got %s", want, got)
+	}
+	if want, got := 2, len(bond0.slaves); want != got {
+		t.Fatalf("want %d slaves, got %d", want, got)
+	}
+	if !bond0.slaves[0].up || bond0.slaves[1].up {
+		t.Errorf("want eth0 up and eth1 down, got %+v", bond0.slaves)
+	}
+
+	bond1 := bonds[1]
+	if want, got := "IEEE 802.3ad Dynamic link aggregation", bond1.mode; want != got {
+		t.Errorf("want mode %s, got %s", want, got)
+	}
+	if bond1.up || bond1.activeSlave != "" {
+		t.Errorf("want bond1 down without active slave, got %+v", bond1)
+	}
+	if want, got := 1, len(bond1.slaves); want != got {
+		t.Errorf("want %d slaves, got %d", want, got)
+	}
+}
+
+func TestOvsBondingParseStats(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/bonding")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+
+	bonds := parseOvsBondingStats(structuredStats["ovs_bonding"])
+	if want, got := 2, len(bonds); want != got {
+		t.Fatalf("want %d bonds, got %d", want, got)
+	}
+
+	bond1 := bonds[0]
+	if want, got := "active-backup", bond1.mode; want != got {
+		t.Errorf("want mode %s, got %s", want, got)
+	}
+	if want, got := "eth3", bond1.activeSlave; want != got {
+		t.Errorf("want active slave %s, got %s", want, got)
+	}
+	if want, got := 2, len(bond1.slaves); want != got {
+		t.Fatalf("want %d slaves, got %d", want, got)
+	}
+	if !bond1.slaves[0].up || bond1.slaves[1].up {
+		t.Errorf("want eth3 enabled and eth4 disabled, got %+v", bond1.slaves)
+	}
+
+	// members and no active member, as reported by newer Open vSwitch releases
+	bond2 := bonds[1]
+	if want, got := "", bond2.activeSlave; want != got {
+		t.Errorf("want no active member, got %s", got)
+	}
+	if want, got := 1, len(bond2.slaves); want != got || bond2.slaves[0].name != "eth5" || !bond2.slaves[0].up {
+		t.Errorf("want member eth5 enabled, got %+v", bond2.slaves)
+	}
+}
@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"bytes"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// the cached(<timestamp>,<interval>) option of sections written by
+	// plugins running asynchronously, e.g. <<<chrony:cached(1565610130,30)>>>
+	cachedSectionHeader = regexp.MustCompile(`^<<<([\w_]+):(?:[^<>]*:)?cached\((\d+),(\d+)\)[^<>]*>>>$`)
+
+	// the sections kept per target until they expire
+	sectionCaches      = make(map[string]map[string]cachedSection)
+	sectionCachesMutex sync.Mutex
+)
+
+type cachedSection struct {
+	lines   []string
+	expires time.Time
+}
+
+// sectionExpiries returns when the sections with a cached(...) header expire,
+// i.e. when their interval has passed since they were written.
+func sectionExpiries(raw *bytes.Buffer) map[string]time.Time {
+	expiries := make(map[string]time.Time)
+	scanner := newLineScanner(raw)
+	for scanner.Scan() {
+		match := cachedSectionHeader.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		written, err := strconv.ParseInt(match[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		interval, err := strconv.ParseInt(match[3], 10, 64)
+		if err != nil {
+			continue
+		}
+		expiries[match[1]] = time.Unix(written+interval, 0)
+	}
+	return expiries
+}
+
+// mergeCachedSections remembers the target's sections that have a cached(...)
+// header or a configured interval, and adds those the agent run omitted
+// until they expire. It returns the sections added, which were handled by
+// previous scrapes already.
+func (mc CheckMKCollector) mergeCachedSections(structuredStats *map[string]*[]string, expiries map[string]time.Time) map[string]bool {
+	now := time.Now()
+	for name, interval := range mc.target.SectionIntervals {
+		if _, ok := expiries[name]; !ok {
+			expiries[name] = now.Add(interval)
+		}
+	}
+
+	sectionCachesMutex.Lock()
+	defer sectionCachesMutex.Unlock()
+	cache, ok := sectionCaches[mc.target.Name]
+	if !ok {
+		cache = make(map[string]cachedSection)
+		sectionCaches[mc.target.Name] = cache
+	}
+	for name, expires := range expiries {
+		if stats, ok := (*structuredStats)[name]; ok {
+			cache[name] = cachedSection{lines: append([]string{}, *stats...), expires: expires}
+		}
+	}
+	replayed := make(map[string]bool)
+	for name, section := range cache {
+		if _, ok := (*structuredStats)[name]; ok {
+			continue
+		}
+		if now.After(section.expires) {
+			log.Debugf("Cached section '%s' of '%s' expired", name, mc.target.Name)
+			delete(cache, name)
+			continue
+		}
+		log.Debugf("Using cached section '%s' of '%s', expiring at %s", name, mc.target.Name, section.expires)
+		lines := append([]string{}, section.lines...)
+		(*structuredStats)[name] = &lines
+		replayed[name] = true
+	}
+	return replayed
+}
@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bverschueren/check_mk_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMergeCachedSections(t *testing.T) {
+	now := time.Now().Unix()
+	mc := CheckMKCollector{target: config.Target{
+		Name:             "cached",
+		SectionIntervals: map[string]time.Duration{"mem": time.Hour},
+	}}
+	run := func(output string) *map[string]*[]string {
+		raw := bytes.NewBufferString(output)
+		structuredStats := structureRawStats(raw)
+		mc.mergeCachedSections(structuredStats, sectionExpiries(raw))
+		return structuredStats
+	}
+
+	run(fmt.Sprintf("<<<check_mk>>>\nVersion: 2.0.0\n"+
+		"<<<chrony:cached(%d,3600)>>>\nReference ID : 192.168.1.1\n"+
+		"<<<mk_foo:sep(0):cached(%d,30)>>>\nfoo\n"+
+		"<<<mem>>>\nMemTotal: 8052832 kB\n"+
+		"<<<df>>>\n/dev/sda1 ext4 1 1 1 1%% /\n", now-60, now-60))
+
+	stats := run("<<<check_mk>>>\nVersion: 2.0.0\n")
+	if chrony, ok := (*stats)["chrony"]; !ok || len(*chrony) != 1 || (*chrony)[0] != "Reference ID : 192.168.1.1" {
+		t.Error("want the chrony section to be kept for its interval")
+	}
+	if _, ok := (*stats)["mem"]; !ok {
+		t.Error("want the mem section to be kept for its configured interval")
+	}
+	if _, ok := (*stats)["mk_foo"]; ok {
+		t.Error("want the expired mk_foo section to be dropped")
+	}
+	if _, ok := (*stats)["df"]; ok {
+		t.Error("want the df section without interval not to be kept")
+	}
+
+	stats = run(fmt.Sprintf("<<<chrony:cached(%d,3600)>>>\nReference ID : 192.168.1.2\n", now))
+	stats = run("")
+	if chrony := (*stats)["chrony"]; chrony == nil || (*chrony)[0] != "Reference ID : 192.168.1.2" {
+		t.Error("want the latest chrony section to be kept")
+	}
+}
+
+func TestCachedLogwatchReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	lines := "[[[/var/log/messages]]]\nC kernel: I/O error\n"
+	empty := filepath.Join(dir, "empty")
+	ioutil.WriteFile(empty, []byte("<<<check_mk>>>\nVersion: 2.0.0\n"), 0644)
+
+	for name, c := range map[string]struct {
+		header    string
+		intervals map[string]time.Duration
+	}{
+		// mk_logwatch run asynchronously
+		"cached-logwatch":   {fmt.Sprintf("<<<logwatch:cached(%d,3600)>>>", time.Now().Unix()), nil},
+		"interval-logwatch": {"<<<logwatch>>>", map[string]time.Duration{"logwatch": time.Hour}},
+	} {
+		output := filepath.Join(dir, name)
+		ioutil.WriteFile(output, []byte(c.header+"\n"+lines), 0644)
+		mc, err := NewMKCheckCollector(config.Target{
+			Name:             name,
+			Transport:        "exec",
+			Command:          "cat " + output,
+			SectionIntervals: c.intervals,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resetLogwatchCounts(name)
+
+		for i := 0; i < 3; i++ {
+			ch := make(chan prometheus.Metric, 100)
+			mc.Collect(ch)
+			close(ch)
+			reported := false
+			for m := range ch {
+				reported = reported || strings.Contains(m.Desc().String(), "check_mk_logwatch_lines_total")
+			}
+			if !reported {
+				t.Errorf("want the logwatch lines of %s reported on scrape %d", name, i)
+			}
+			if want, got := 1.0, logwatchCount(name, "/var/log/messages", "critical"); want != got {
+				t.Errorf("want %f critical lines for %s after scrape %d, got %f", want, name, i, got)
+			}
+			// the agent omits the section until it's due again
+			mc.Command = "cat " + empty
+		}
+	}
+}
@@ -3,12 +3,12 @@ package collector
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"github.com/bverschueren/check_mk_exporter/config"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/crypto/ssh"
 	"io/ioutil"
-	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -20,39 +20,112 @@ const (
 )
 
 var (
-	factories  = make(map[string]func() (Collector, error))
-	collectors = make(map[string]Collector)
-	command    = "check_mk_agent"
+	factories   = make(map[string]func(config.Target) (Collector, error))
+	osFactories = make(map[string]map[string]func(config.Target) (Collector, error))
 )
 
 func registerCollector(collector string, factory func() (Collector, error)) {
+	factories[collector] = func(config.Target) (Collector, error) {
+		return factory()
+	}
+}
+
+// registerTargetCollector registers a collector that depends on the target
+// it collects from, e.g. to keep state between scrapes.
+func registerTargetCollector(collector string, factory func(config.Target) (Collector, error)) {
 	factories[collector] = factory
 }
 
+// registerOSCollector registers a collector for a section whose format
+// differs for the AgentOS reported in the check_mk section, e.g. 'windows'.
+// It takes precedence over the collector registered for all agents.
+func registerOSCollector(agentOS, collector string, factory func() (Collector, error)) {
+	if _, ok := osFactories[agentOS]; !ok {
+		osFactories[agentOS] = make(map[string]func(config.Target) (Collector, error))
+	}
+	osFactories[agentOS][collector] = func(config.Target) (Collector, error) {
+		return factory()
+	}
+}
+
 type Collector interface {
 	Update(unstructuredStats *[]string, ch chan<- prometheus.Metric) error
 }
 
+// PiggybackCollector is implemented by collectors for sections the agent
+// piggybacks for other hosts, e.g. docker containers. Those sections are
+// passed along with the name of the host they belong to.
+type PiggybackCollector interface {
+	UpdatePiggyback(host string, unstructuredStats *[]string, ch chan<- prometheus.Metric) error
+}
+
+// DeltaCollector is implemented by collectors for sections reporting what
+// changed since the agent's previous run, e.g. logwatch. Output handled
+// before, like spooled output scraped again, is passed to Replay instead of
+// Update, so it isn't counted twice.
+type DeltaCollector interface {
+	Replay(unstructuredStats *[]string, ch chan<- prometheus.Metric) error
+}
+
 type CheckMKCollector struct {
-	target     config.Target
-	collectors map[string]Collector
-	Command    string
+	target       config.Target
+	collectors   map[string]Collector
+	osCollectors map[string]map[string]Collector
+	Command      string
 }
 
 func NewMKCheckCollector(sshtarget config.Target) (CheckMKCollector, error) {
 
+	collectors := newCollectors(sshtarget, factories)
+	osCollectors := make(map[string]map[string]Collector)
+	for agentOS, f := range osFactories {
+		osCollectors[agentOS] = newCollectors(sshtarget, f)
+	}
+	return CheckMKCollector{
+		target:       sshtarget,
+		collectors:   collectors,
+		osCollectors: osCollectors,
+		Command:      sshtarget.Command,
+	}, nil
+}
+
+func newCollectors(sshtarget config.Target, factories map[string]func(config.Target) (Collector, error)) map[string]Collector {
+	collectors := make(map[string]Collector)
 	for collector, factory := range factories {
-		var err error
-		collectors[collector], err = factory()
+		c, err := factory(sshtarget)
 		if err != nil {
 			log.Errorf("Unable to initialize factory for collector '%s'", collector)
+			continue
 		}
+		collectors[collector] = c
 	}
-	return CheckMKCollector{
-		target:     sshtarget,
-		collectors: collectors,
-		Command:    command,
-	}, nil
+	return collectors
+}
+
+// collectorsFor returns the collectors for the agent's OS.
+func (mc CheckMKCollector) collectorsFor(agentOS string) map[string]Collector {
+	osCollectors, ok := mc.osCollectors[agentOS]
+	if !ok {
+		return mc.collectors
+	}
+	collectors := make(map[string]Collector)
+	for name, c := range mc.collectors {
+		collectors[name] = c
+	}
+	for name, c := range osCollectors {
+		collectors[name] = c
+	}
+	return collectors
+}
+
+// agentOS returns the AgentOS reported in the check_mk section, e.g. 'linux'
+// or 'windows'.
+func agentOS(structuredStats *map[string]*[]string) string {
+	stats, ok := (*structuredStats)["check_mk"]
+	if !ok {
+		return ""
+	}
+	return keyValueStats(stats)["AgentOS"]
 }
 
 func (mc CheckMKCollector) connect() (*ssh.Session, ssh.Conn, error) {
@@ -91,15 +164,30 @@ func (mc CheckMKCollector) connect() (*ssh.Session, ssh.Conn, error) {
 		log.Fatalf("Failed to create session: %s", err)
 		return nil, nil, err
 	}
-	session.Stdout = os.Stdout
-	session.Stderr = os.Stderr
-
 	return session, connection, nil
 }
 
+// collectRawStats fetches the agent output using the target's transport.
 func (mc CheckMKCollector) collectRawStats() (*bytes.Buffer, error) {
+	switch mc.target.Transport {
+	case "", "ssh":
+		return mc.collectSSHRawStats()
+	case "tls":
+		return mc.collectTLSRawStats()
+	case "exec":
+		return mc.collectExecRawStats()
+	case "file":
+		return mc.collectFileRawStats()
+	case "push":
+		return mc.collectPushRawStats()
+	default:
+		return nil, fmt.Errorf("unknown transport '%s' for target '%s'", mc.target.Transport, mc.target.Name)
+	}
+}
+
+func (mc CheckMKCollector) collectSSHRawStats() (*bytes.Buffer, error) {
 	log.Debugf("Collecting stats from %s", mc.target.HostName)
-	var stdoutBuf bytes.Buffer
+	var stdoutBuf, stderrBuf bytes.Buffer
 
 	session, connection, err := mc.connect()
 	if err != nil {
@@ -108,36 +196,68 @@ func (mc CheckMKCollector) collectRawStats() (*bytes.Buffer, error) {
 	}
 
 	session.Stdout = &stdoutBuf
-	err = session.Run(mc.Command)
+	session.Stderr = &stderrBuf
+	err = session.Run(mc.remoteCommand())
 
 	log.Trace("Raw check_mk stats: " + stdoutBuf.String())
 
 	session.Close()
 	connection.Close()
-	return &stdoutBuf, nil
+	if exitErr, ok := err.(*ssh.ExitError); ok {
+		e := agentExitError{code: exitErr.ExitStatus(), signal: exitErr.Signal(), output: &stdoutBuf}
+		if e.signal != "" {
+			e.code = -1
+		}
+		err = e
+	}
+	mc.logAgentRun(err, stderrBuf.String())
+	if err != nil {
+		if _, ok := err.(agentExitError); !ok {
+			return nil, err
+		}
+	}
+	return &stdoutBuf, err
+}
+
+// splitPiggyback separates the output of the monitored host from the output
+// it piggybacks for other hosts between <<<<host>>>> and <<<<>>>> lines.
+func splitPiggyback(raw *bytes.Buffer) (*bytes.Buffer, map[string]*bytes.Buffer) {
+	scanner := newLineScanner(raw)
+
+	re := regexp.MustCompile("^<<<<([^<>]*)>>>>$")
+	own := new(bytes.Buffer)
+	piggybacked := make(map[string]*bytes.Buffer)
+	cur := own
+
+	for scanner.Scan() {
+		in := scanner.Text()
+		if match := re.FindStringSubmatch(in); match != nil {
+			if match[1] == "" {
+				cur = own
+				continue
+			}
+			log.Debugf("Found piggyback data for %s", match[1])
+			if _, ok := piggybacked[match[1]]; !ok {
+				piggybacked[match[1]] = new(bytes.Buffer)
+			}
+			cur = piggybacked[match[1]]
+			continue
+		}
+		cur.WriteString(in + "\n")
+	}
+	return own, piggybacked
 }
 
 // TODO: allow overriding subsystems
 func structureRawStats(raw *bytes.Buffer) *map[string]*[]string {
-	scanner := bufio.NewScanner(strings.NewReader(raw.String()))
+	scanner := newLineScanner(raw)
 	log.Trace("Raw stats: ", raw.String())
 
-	re := regexp.MustCompile("<<<([\\w_]+)>>>")
+	// section headers may carry options, e.g. <<<chrony:cached(1565610130,30)>>>
+	re := regexp.MustCompile("<<<([\\w_]+)(?::[^<>]*)?>>>")
 	structuredStats := make(map[string]*[]string)
-	// list stats in temporary map to ensure unique elemets
-	tempStats := make(map[string]struct{})
-	curStat, prevStat := "", ""
-
-	keyMapToList := func() *[]string {
-		// convert map to slice of keys
-		keys := new([]string)
-		log.Tracef("Found for %s:", curStat)
-		for k, _ := range tempStats {
-			log.Trace(k)
-			*keys = append(*keys, k)
-		}
-		return keys
-	}
+	// lines are kept in order, as some sections are structured by subheaders
+	var curStat *[]string
 
 	for scanner.Scan() {
 
@@ -145,48 +265,157 @@ func structureRawStats(raw *bytes.Buffer) *map[string]*[]string {
 		in := scanner.Text()
 
 		if match := re.FindStringSubmatch(in); match != nil {
-			prevStat = curStat
-			curStat = match[1]
-			log.Debugf("Found stat %s", curStat)
-			if prevStat == "" {
-				prevStat = curStat
-			}
-			if prevStat != curStat {
-				// move the stat from previous iteration to list
-				structuredStats[prevStat] = keyMapToList()
-				log.Debugf("structured %s", prevStat)
-				tempStats = make(map[string]struct{})
+			log.Debugf("Found stat %s", match[1])
+			// a section can be emitted more than once, e.g. by plugins
+			if _, ok := structuredStats[match[1]]; !ok {
+				structuredStats[match[1]] = new([]string)
 			}
-		} else {
-			tempStats[in] = struct{}{}
+			curStat = structuredStats[match[1]]
+			continue
+		}
+		if curStat == nil {
+			continue
 		}
+		*curStat = append(*curStat, in)
 	}
-	// process stat from the last iteration
-	structuredStats[curStat] = keyMapToList()
 
 	return &structuredStats
 }
 
+// newLineScanner allows for long lines, as written by plugins emitting JSON.
+func newLineScanner(raw *bytes.Buffer) *bufio.Scanner {
+	scanner := bufio.NewScanner(strings.NewReader(raw.String()))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	return scanner
+}
+
 func (mc CheckMKCollector) Collect(ch chan<- prometheus.Metric) {
+	mc.collect(ch)
+}
+
+// collect sends the metrics of the target to the channel, returning the
+// sections of the agent output, or nil if it wasn't fetched.
+func (mc CheckMKCollector) collect(ch chan<- prometheus.Metric) *map[string]*[]string {
+	rawStats, err := mc.fetchRawStats()
+	// reported for targets expecting encrypted output, or when decryption failed
+	_, failed := err.(decryptionError)
+	if failed || (rawStats != nil && (mc.target.Passphrase != "" || mc.target.PassphraseFile != "")) {
+		ch <- prometheus.MustNewConstMetric(decryptionFailedDesc, prometheus.GaugeValue, boolToFloat(failed))
+	}
+	if exitErr, ok := err.(agentExitError); ok {
+		ch <- prometheus.MustNewConstMetric(exitCodeDesc, prometheus.GaugeValue, float64(exitErr.code), exitErr.signal)
+	} else if (err == nil || failed) && mc.runsAgent() {
+		ch <- prometheus.MustNewConstMetric(exitCodeDesc, prometheus.GaugeValue, 0, "")
+	}
+	if age, ok := mc.outputAge(); ok {
+		ch <- prometheus.MustNewConstMetric(outputAgeDesc, prometheus.GaugeValue, age.Seconds())
+	}
+	if rawStats == nil {
+		return nil
+	}
+	return mc.collectOutput(rawStats, ch)
+}
+
+// collectOutput hands the sections of the agent output to their collectors,
+// and the sections piggybacked for other hosts to the collectors knowing
+// about them. It returns the target's own sections, including those kept from
+// previous runs.
+func (mc CheckMKCollector) collectOutput(rawStats *bytes.Buffer, ch chan<- prometheus.Metric) *map[string]*[]string {
 	wg := sync.WaitGroup{}
-	wg.Add(len(mc.collectors))
-	if rawStats, err := mc.collectRawStats(); err == nil {
-		structuredRawStats := structureRawStats(rawStats)
-		for name, c := range mc.collectors {
-			log.Debugf("Collecting from '%s'", name)
-			if _, ok := (*structuredRawStats)[name]; !ok {
-				log.Debugf("No raw stats found for '%s'", name)
+	finish := func() {}
+	ownStats, piggybackedStats := splitPiggyback(rawStats)
+	structuredRawStats := structureRawStats(ownStats)
+	replayed := mc.mergeCachedSections(structuredRawStats, sectionExpiries(ownStats))
+	if labelStats, ok := (*structuredRawStats)["labels"]; ok && hostLabelsMode == "attach" {
+		ch, finish = attachHostLabels(ch, parseHostLabels(labelStats))
+	}
+	replay := mc.outputHandled()
+	agentOS := agentOS(structuredRawStats)
+	log.Debugf("Agent OS of %s is '%s'", mc.target.HostName, agentOS)
+	collectors := mc.collectorsFor(agentOS)
+	for name, c := range collectors {
+		if !mc.wantsSection(name) {
+			continue
+		}
+		log.Debugf("Collecting from '%s'", name)
+		if _, ok := (*structuredRawStats)[name]; !ok {
+			log.Debugf("No raw stats found for '%s'", name)
+			continue
+		}
+		wg.Add(1)
+		go func(name string, c Collector) {
+			if dc, ok := c.(DeltaCollector); ok && (replay || replayed[name]) {
+				dc.Replay((*structuredRawStats)[name], ch)
+			} else {
+				c.Update((*structuredRawStats)[name], ch)
+			}
+			wg.Done()
+		}(name, c)
+	}
+	for host, hostRawStats := range piggybackedStats {
+		structuredHostStats := structureRawStats(hostRawStats)
+		for name, c := range collectors {
+			pc, ok := c.(PiggybackCollector)
+			if !ok {
 				continue
 			}
-			go func(name string, c Collector) {
-				c.Update((*structuredRawStats)[name], ch)
+			if _, ok := (*structuredHostStats)[name]; !ok {
+				continue
+			}
+			log.Debugf("Collecting from '%s' for piggybacked host '%s'", name, host)
+			wg.Add(1)
+			go func(host, name string, pc PiggybackCollector) {
+				pc.UpdatePiggyback(host, (*structuredHostStats)[name], ch)
 				wg.Done()
-			}(name, c)
+			}(host, name, pc)
 		}
 	}
 	wg.Wait()
+	finish()
+	return structuredRawStats
 }
 
 func (mc CheckMKCollector) Describe(ch chan<- *prometheus.Desc) {
 	// TODO: implement metrics about the scrape
 }
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type subsection struct {
+	name  string
+	lines []string
+}
+
+// splitSubsections groups the lines of a section by their '[[name]]' or
+// '[[[name]]]' subsection header, as used by plugins monitoring multiple
+// instances. Lines before the first header belong to defaultName.
+func splitSubsections(unstructuredStats *[]string, defaultName string) []subsection {
+	re := regexp.MustCompile(`^\[\[\[?([^\[\]]*)\]?\]\]$`)
+	subsections := []subsection{}
+	index := make(map[string]int)
+	get := func(name string) int {
+		if _, ok := index[name]; !ok {
+			index[name] = len(subsections)
+			subsections = append(subsections, subsection{name: name})
+		}
+		return index[name]
+	}
+
+	cur := -1
+	for _, in := range *unstructuredStats {
+		if match := re.FindStringSubmatch(strings.TrimSpace(in)); match != nil {
+			cur = get(match[1])
+			continue
+		}
+		if cur < 0 {
+			cur = get(defaultName)
+		}
+		subsections[cur].lines = append(subsections[cur].lines, in)
+	}
+	return subsections
+}
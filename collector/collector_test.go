@@ -30,15 +30,20 @@ func TestStructureRawStats(t *testing.T) {
 
 	structuredStats := (*structureRawStats(rawStats))
 
-	if want, got := 7, len((*structuredStats["df"])); want != got {
+	if want, got := 8, len((*structuredStats["df"])); want != got {
 		t.Errorf("want %d df elements, got %d", want, got)
 	}
 
+	df := dfCollector{}
+	if want, got := 7, len(df.parseStats(structuredStats["df"])); want != got {
+		t.Errorf("want %d unique df filesystems, got %d", want, got)
+	}
+
 	if want, got := 8, len((*structuredStats["diskstat"])); want != got {
 		t.Errorf("want %d diskstat elements, got %d", want, got)
 	}
 
-	if want, got := 2, len((*structuredStats["mounts"])); want != got {
+	if want, got := 3, len((*structuredStats["mounts"])); want != got {
 		t.Errorf("want %d mount elements, got %d", want, got)
 	}
 
@@ -0,0 +1,197 @@
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	exitCodeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "agent", "exit_code"),
+		"Exit code of the agent run, -1 if killed by a signal",
+		[]string{"signal"}, nil,
+	)
+)
+
+const (
+	// pass the target's Sections and Plugins to wrappers of the agent
+	sectionsEnv = "CHECK_MK_EXPORTER_SECTIONS"
+	pluginsEnv  = "CHECK_MK_EXPORTER_PLUGINS"
+
+	// the length of the agent's stderr that's logged
+	maxStderrLength = 1024
+)
+
+// agentExitError is returned for agent runs exiting non-zero, carrying the
+// output written nonetheless.
+type agentExitError struct {
+	code   int
+	signal string
+	output *bytes.Buffer
+}
+
+func (e agentExitError) Error() string {
+	if e.signal != "" {
+		return fmt.Sprintf("agent killed by signal %s", e.signal)
+	}
+	return fmt.Sprintf("agent exited with status %d", e.code)
+}
+
+// runsAgent tells whether the target's transport runs the agent, rather than
+// reading its output.
+func (mc CheckMKCollector) runsAgent() bool {
+	switch mc.target.Transport {
+	case "", "ssh", "exec":
+		return true
+	}
+	return false
+}
+
+// logAgentRun logs the outcome of the agent run along with its (truncated)
+// stderr.
+func (mc CheckMKCollector) logAgentRun(err error, stderr string) {
+	stderr = truncateString(strings.TrimSpace(stderr), maxStderrLength)
+	switch {
+	case err != nil && stderr != "":
+		log.Warnf("Running '%s' for '%s' failed: %s, stderr: %s", mc.Command, mc.target.Name, err, stderr)
+	case err != nil:
+		log.Warnf("Running '%s' for '%s' failed: %s", mc.Command, mc.target.Name, err)
+	case stderr != "":
+		log.Infof("Stderr of '%s' for '%s': %s", mc.Command, mc.target.Name, stderr)
+	}
+}
+
+// truncateString cuts the string to at most max bytes, marked by "...", without
+// splitting a multi-byte character.
+func truncateString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "..."
+}
+
+// agentEnv returns the target's environment of the agent run as sorted
+// 'NAME=value' pairs.
+func (mc CheckMKCollector) agentEnv() []string {
+	env := []string{}
+	for name, value := range mc.target.Env {
+		env = append(env, name+"="+value)
+	}
+	if len(mc.target.Sections) > 0 {
+		env = append(env, sectionsEnv+"="+strings.Join(mc.target.Sections, " "))
+	}
+	if len(mc.target.Plugins) > 0 {
+		env = append(env, pluginsEnv+"="+strings.Join(mc.target.Plugins, " "))
+	}
+	sort.Strings(env)
+	return env
+}
+
+// remoteCommand returns the shell command running the agent over SSH, e.g.
+//
+//	sudo -n MK_CONFDIR='/etc/check_mk' /usr/bin/check_mk_agent
+func (mc CheckMKCollector) remoteCommand() string {
+	parts := []string{}
+	if mc.target.Sudo {
+		parts = append(parts, "sudo", "-n")
+	}
+	for _, env := range mc.agentEnv() {
+		kv := strings.SplitN(env, "=", 2)
+		parts = append(parts, kv[0]+"="+shellQuote(kv[1]))
+	}
+	return strings.Join(append(parts, mc.Command), " ")
+}
+
+// localCommand returns the arguments running the agent on the exporter's
+// host. Its environment is passed to sudo as arguments, as sudo resets it,
+// which sudoers has to allow by the SETENV tag.
+func (mc CheckMKCollector) localCommand() ([]string, error) {
+	args, err := splitCommand(mc.Command)
+	if err != nil || !mc.target.Sudo || len(args) == 0 {
+		return args, err
+	}
+	return append(append([]string{"sudo", "-n"}, mc.agentEnv()...), args...), nil
+}
+
+// splitCommand splits the command into its arguments like a POSIX shell
+// does, without expanding anything:
+//
+//	sh -c 'cat "$1"' agent /var/lib/check_mk/agent\ output
+//
+// Single quotes keep everything, double quotes keep all but escaped '"',
+// '\\', '$' and '`', and a backslash outside of quotes keeps the next
+// character.
+func splitCommand(command string) ([]string, error) {
+	args := []string{}
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range command {
+		switch {
+		case escaped:
+			if quote == '"' && !strings.ContainsRune("\"\\$`\n", r) {
+				arg.WriteRune('\\')
+			}
+			if r != '\n' {
+				arg.WriteRune(r)
+				inArg = true
+			}
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if escaped || quote != 0 {
+		return nil, fmt.Errorf("unterminated quote or escape in command '%s'", command)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// wantsSection tells whether the section is collected, i.e. it's one of the
+// target's Sections, if any.
+func (mc CheckMKCollector) wantsSection(name string) bool {
+	if len(mc.target.Sections) == 0 {
+		return true
+	}
+	for _, section := range mc.target.Sections {
+		if section == name {
+			return true
+		}
+	}
+	return false
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"github.com/bverschueren/check_mk_exporter/config"
+	"reflect"
+	"testing"
+)
+
+func TestAgentCommand(t *testing.T) {
+	target := config.Target{
+		Command:  "/usr/bin/check_mk_agent",
+		Env:      map[string]string{"MK_CONFDIR": "/etc/check_mk", "NAME": "it's"},
+		Sections: []string{"df", "mem"},
+	}
+	mc := CheckMKCollector{target: target, Command: target.Command}
+
+	if want, got := `CHECK_MK_EXPORTER_SECTIONS='df mem' MK_CONFDIR='/etc/check_mk' NAME='it'\''s' /usr/bin/check_mk_agent`, mc.remoteCommand(); want != got {
+		t.Errorf("want remote command %q, got %q", want, got)
+	}
+	got, err := mc.localCommand()
+	if want := []string{"/usr/bin/check_mk_agent"}; err != nil || !reflect.DeepEqual(want, got) {
+		t.Errorf("want local command %q, got %q", want, got)
+	}
+
+	mc.target.Sudo = true
+	mc.target.Env = nil
+	if want, got := `sudo -n CHECK_MK_EXPORTER_SECTIONS='df mem' /usr/bin/check_mk_agent`, mc.remoteCommand(); want != got {
+		t.Errorf("want remote command %q, got %q", want, got)
+	}
+	got, err = mc.localCommand()
+	if want := []string{"sudo", "-n", "CHECK_MK_EXPORTER_SECTIONS=df mem", "/usr/bin/check_mk_agent"}; err != nil || !reflect.DeepEqual(want, got) {
+		t.Errorf("want local command %q, got %q", want, got)
+	}
+
+	if !mc.wantsSection("df") || mc.wantsSection("cpu") {
+		t.Error("want only the target's sections to be collected")
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	for command, want := range map[string][]string{
+		"/usr/bin/check_mk_agent":               {"/usr/bin/check_mk_agent"},
+		"  docker exec  web check_mk_agent ":    {"docker", "exec", "web", "check_mk_agent"},
+		`sh -c 'cat "$1" >&2' agent`:            {"sh", "-c", `cat "$1" >&2`, "agent"},
+		`cat "/var/lib/agent output" it\'s`:     {"cat", "/var/lib/agent output", "it's"},
+		`echo "a \"quoted\" \$HOME \n" '' x""y`: {"echo", `a "quoted" $HOME \n`, "", "xy"},
+		"check_mk_agent \\\n --debug":           {"check_mk_agent", "--debug"},
+		"":                                      {},
+	} {
+		got, err := splitCommand(command)
+		if err != nil || !reflect.DeepEqual(want, got) {
+			t.Errorf("want %q split into %q, got %q (%v)", command, want, got, err)
+		}
+	}
+	for _, command := range []string{`sh -c 'echo`, `echo "a`, `echo a\`} {
+		if _, err := splitCommand(command); err == nil {
+			t.Errorf("want an error for %q", command)
+		}
+	}
+}
+
+func TestTruncateString(t *testing.T) {
+	for _, c := range []struct {
+		s, want string
+	}{
+		{"short", "short"},
+		{"exceeding", "excee..."},
+		// 'ü' takes the 5th and 6th byte
+		{"fehlüberwachung", "fehl..."},
+		{"fehü", "fehü"},
+	} {
+		if got := truncateString(c.s, 5); c.want != got {
+			t.Errorf("want %q truncated to %q, got %q", c.s, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+type cpuCollector struct {
+	format     string
+	Load1Desc  *prometheus.Desc
+	Load5Desc  *prometheus.Desc
+	Load15Desc *prometheus.Desc
+	CountDesc  *prometheus.Desc
+}
+
+type cpuStats struct {
+	load     [3]float64
+	count    float64
+	hasCount bool
+}
+
+func init() {
+	registerOSCollector("aix", "cpu", NewCpuCollector)
+	registerOSCollector("solaris", "cpu", NewCpuCollector)
+	registerOSCollector("freebsd", "cpu", NewFreebsdCpuCollector)
+}
+
+// NewFreebsdCpuCollector handles the cpu section of the FreeBSD agent, which
+// lacks the last PID column.
+func NewFreebsdCpuCollector() (Collector, error) {
+	c, err := NewCpuCollector()
+	if err != nil {
+		return nil, err
+	}
+	cpu := c.(cpuCollector)
+	cpu.format = "freebsd"
+	return cpu, nil
+}
+
+func NewCpuCollector() (Collector, error) {
+	subsystem := "cpu"
+
+	Load1Desc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "load1"),
+		"1m load average",
+		nil, nil,
+	)
+	Load5Desc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "load5"),
+		"5m load average",
+		nil, nil,
+	)
+	Load15Desc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "load15"),
+		"15m load average",
+		nil, nil,
+	)
+	CountDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "count"),
+		"Number of CPUs",
+		nil, nil,
+	)
+	return cpuCollector{
+		Load1Desc:  Load1Desc,
+		Load5Desc:  Load5Desc,
+		Load15Desc: Load15Desc,
+		CountDesc:  CountDesc,
+	}, nil
+}
+
+func (c cpuCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	s, ok := parseCpuStats(unparsedStats, c.format)
+	if !ok {
+		return nil
+	}
+	ch <- prometheus.MustNewConstMetric(c.Load1Desc, prometheus.GaugeValue, s.load[0])
+	ch <- prometheus.MustNewConstMetric(c.Load5Desc, prometheus.GaugeValue, s.load[1])
+	ch <- prometheus.MustNewConstMetric(c.Load15Desc, prometheus.GaugeValue, s.load[2])
+	if s.hasCount {
+		ch <- prometheus.MustNewConstMetric(c.CountDesc, prometheus.GaugeValue, s.count)
+	}
+	return nil
+}
+
+// parseCpuStats handles the load averages followed by, depending on the
+// agent, the running/total processes, the last PID and the number of CPUs:
+//
+//	0.03 0.04 0.05 1/1 0 8		(Solaris)
+//	0.21 0.18 0.15 1/87 4		(FreeBSD)
+//	1.58, 1.49, 1.43 4		(AIX)
+func parseCpuStats(unparsedStats *[]string, format string) (cpuStats, bool) {
+	var s cpuStats
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		fields := strings.Fields(strings.Replace(stat, ",", " ", -1))
+		if len(fields) < 3 {
+			continue
+		}
+		for i := range s.load {
+			load, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return s, false
+			}
+			s.load[i] = load
+		}
+		countField := -1
+		switch {
+		case format == "freebsd" && len(fields) >= 5:
+			countField = len(fields) - 1
+		case len(fields) == 4:
+			countField = 3
+		case len(fields) >= 6:
+			countField = 5
+		}
+		if countField >= 0 {
+			count, err := strconv.ParseFloat(fields[countField], 64)
+			s.count, s.hasCount = count, err == nil
+		}
+		return s, true
+	}
+	return s, false
+}
@@ -10,9 +10,11 @@ import (
 
 var (
 	filesystemLabelNames = []string{"device", "mountpoint", "fstype"}
+	dfMarker             = regexp.MustCompile(`^\[([a-z0-9_-]+)\]`)
 )
 
 type dfCollector struct {
+	format         string
 	SizeDesc       *prometheus.Desc
 	UsedDesc       *prometheus.Desc
 	AvailDesc      *prometheus.Desc
@@ -30,6 +32,30 @@ type filesystemStats struct {
 
 func init() {
 	registerCollector("df", NewDfCollector)
+	registerOSCollector("windows", "df", NewWindowsDfCollector)
+	registerOSCollector("solaris", "df", NewSolarisDfCollector)
+}
+
+// NewWindowsDfCollector handles the df section of the Windows agent, which
+// separates its columns by tabs as volume names may contain spaces.
+func NewWindowsDfCollector() (Collector, error) {
+	return newDfCollectorWithFormat("windows")
+}
+
+// NewSolarisDfCollector handles the df section of older Solaris agents,
+// which lack the filesystem type column and wrap long device names.
+func NewSolarisDfCollector() (Collector, error) {
+	return newDfCollectorWithFormat("solaris")
+}
+
+func newDfCollectorWithFormat(format string) (Collector, error) {
+	c, err := NewDfCollector()
+	if err != nil {
+		return nil, err
+	}
+	d := c.(dfCollector)
+	d.format = format
+	return d, nil
 }
 
 func NewDfCollector() (Collector, error) {
@@ -90,14 +116,49 @@ func (d dfCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric
 
 func (c dfCollector) parseStats(unparsedStats *[]string) []filesystemStats {
 
+	if c.format == "solaris" {
+		unparsedStats = unwrapSolarisDf(unparsedStats)
+	}
 	stats := []filesystemStats{}
+	// bind mounts show up more than once
+	seen := make(map[string]struct{})
+	inodes := false
 	for _, stat := range *unparsedStats {
 		log.Tracef("[raw-structured] %s", stat)
-		if match, _ := regexp.MatchString("^\\[([a-z0-9_-]+)\\]", stat); match {
+		if match := dfMarker.FindStringSubmatch(stat); match != nil {
+			// inode counts are listed between these markers, not sizes
+			switch match[1] {
+			case "df_inodes_start":
+				inodes = true
+			case "df_inodes_end":
+				inodes = false
+			}
+			log.Debugf("Skipping '%s'", stat)
+			continue
+		}
+		if inodes {
+			log.Debugf("Skipping inodes '%s'", stat)
+			continue
+		}
+		if _, ok := seen[stat]; ok {
+			log.Debugf("Skipping duplicate '%s'", stat)
+			continue
+		}
+		seen[stat] = struct{}{}
+		var fields []string
+		if c.format == "windows" {
+			fields = strings.Split(stat, "\t")
+		} else {
+			fields = strings.Fields(stat)
+		}
+		if c.format == "solaris" && len(fields) == 6 {
+			// no filesystem type column
+			fields = append(fields[:1], append([]string{""}, fields[1:]...)...)
+		}
+		if len(fields) < 7 {
 			log.Debugf("Skipping '%s'", stat)
 			continue
 		}
-		fields := strings.Fields(stat)
 		f_size, _ := strconv.ParseFloat(fields[2], 64)
 		f_used, _ := strconv.ParseFloat(fields[3], 64)
 		f_avail, _ := strconv.ParseFloat(fields[4], 64)
@@ -117,3 +178,42 @@ func (c dfCollector) parseStats(unparsedStats *[]string) []filesystemStats {
 	}
 	return stats
 }
+
+// unwrapSolarisDf joins device names that 'df -k' prints on a line of their
+// own with the line holding their sizes:
+//
+//	rpool/ROOT/solaris-with-a-long-name
+//	                     30707712 12488837 13946236    48%    /
+//
+// A line is only joined onto the next when that one is such a continuation,
+// i.e. it is indented or starts with a number. Markers like
+// [df_inodes_start] are passed on untouched.
+func unwrapSolarisDf(unparsedStats *[]string) *[]string {
+	lines := []string{}
+	stats := *unparsedStats
+	for i := 0; i < len(stats); i++ {
+		stat := stats[i]
+		fields := strings.Fields(stat)
+		if len(fields) == 1 && !dfMarker.MatchString(stat) &&
+			i+1 < len(stats) && isDfContinuation(stats[i+1]) {
+			i++
+			stat = fields[0] + " " + strings.TrimSpace(stats[i])
+		}
+		lines = append(lines, stat)
+	}
+	return &lines
+}
+
+// isDfContinuation tells whether a line holds the sizes of the device named
+// on the line before it.
+func isDfContinuation(stat string) bool {
+	if strings.TrimLeft(stat, " \t") != stat {
+		return true
+	}
+	fields := strings.Fields(stat)
+	if len(fields) == 0 {
+		return false
+	}
+	_, err := strconv.ParseFloat(fields[0], 64)
+	return err == nil
+}
@@ -0,0 +1,281 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+var (
+	dockerNodeStateLabelNames       = []string{"state"}
+	dockerNodeInfoLabelNames        = []string{"name", "version"}
+	dockerContainerLabelNames       = []string{"container"}
+	dockerContainerStatusLabelNames = []string{"container", "status"}
+	dockerContainerCpuLabelNames    = []string{"container", "mode"}
+)
+
+// The docker plugin writes each section as a single JSON document per line
+// (sep(0)), optionally preceded by '@docker_version_info' metadata.
+
+type dockerNodeInfo struct {
+	Name              string
+	ServerVersion     string
+	Containers        float64
+	ContainersRunning float64
+	ContainersPaused  float64
+	ContainersStopped float64
+	Images            float64
+}
+
+type dockerContainerStatus struct {
+	Status    string
+	Running   bool
+	OOMKilled bool
+	ExitCode  float64
+}
+
+type dockerContainerCpu struct {
+	CpuUsage struct {
+		TotalUsage        float64 `json:"total_usage"`
+		UsageInKernelmode float64 `json:"usage_in_kernelmode"`
+		UsageInUsermode   float64 `json:"usage_in_usermode"`
+	} `json:"cpu_usage"`
+	OnlineCpus float64 `json:"online_cpus"`
+}
+
+type dockerContainerMem struct {
+	Usage float64 `json:"usage"`
+	Limit float64 `json:"limit"`
+	Stats struct {
+		Cache float64 `json:"cache"`
+		Rss   float64 `json:"rss"`
+	} `json:"stats"`
+}
+
+type dockerNodeCollector struct {
+	ContainersDesc *prometheus.Desc
+	ImagesDesc     *prometheus.Desc
+	InfoDesc       *prometheus.Desc
+}
+
+// dockerContainerCollector handles the docker_container_* sections, which
+// are piggybacked for each container onto the output of its docker node.
+type dockerContainerCollector struct {
+	section        string
+	StatusDesc     *prometheus.Desc
+	RunningDesc    *prometheus.Desc
+	OOMKilledDesc  *prometheus.Desc
+	ExitCodeDesc   *prometheus.Desc
+	CpuDesc        *prometheus.Desc
+	OnlineCpusDesc *prometheus.Desc
+	MemUsageDesc   *prometheus.Desc
+	MemLimitDesc   *prometheus.Desc
+	MemCacheDesc   *prometheus.Desc
+	MemRssDesc     *prometheus.Desc
+}
+
+func init() {
+	registerCollector("docker_node_info", NewDockerNodeCollector)
+	registerCollector("docker_container_status", NewDockerContainerStatusCollector)
+	registerCollector("docker_container_cpu", NewDockerContainerCpuCollector)
+	registerCollector("docker_container_mem", NewDockerContainerMemCollector)
+}
+
+func NewDockerNodeCollector() (Collector, error) {
+	subsystem := "docker_node"
+
+	ContainersDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "containers"),
+		"Number of containers on the docker node by state",
+		dockerNodeStateLabelNames, nil,
+	)
+	ImagesDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "images"),
+		"Number of images on the docker node",
+		nil, nil,
+	)
+	InfoDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "info"),
+		"Docker node name and version",
+		dockerNodeInfoLabelNames, nil,
+	)
+	return dockerNodeCollector{
+		ContainersDesc: ContainersDesc,
+		ImagesDesc:     ImagesDesc,
+		InfoDesc:       InfoDesc,
+	}, nil
+}
+
+func (d dockerNodeCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	var info dockerNodeInfo
+	if err := decodeDockerSection(unparsedStats, &info); err != nil {
+		log.Errorf("Unable to parse docker node info: %s", err)
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		d.InfoDesc, prometheus.GaugeValue, 1, info.Name, info.ServerVersion,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		d.ImagesDesc, prometheus.GaugeValue, info.Images,
+	)
+	for state, count := range map[string]float64{
+		"running": info.ContainersRunning,
+		"paused":  info.ContainersPaused,
+		"stopped": info.ContainersStopped,
+	} {
+		ch <- prometheus.MustNewConstMetric(
+			d.ContainersDesc, prometheus.GaugeValue, count, state,
+		)
+	}
+	return nil
+}
+
+func NewDockerContainerStatusCollector() (Collector, error) {
+	return newDockerContainerCollector("docker_container_status"), nil
+}
+
+func NewDockerContainerCpuCollector() (Collector, error) {
+	return newDockerContainerCollector("docker_container_cpu"), nil
+}
+
+func NewDockerContainerMemCollector() (Collector, error) {
+	return newDockerContainerCollector("docker_container_mem"), nil
+}
+
+func newDockerContainerCollector(section string) dockerContainerCollector {
+	subsystem := "docker_container"
+
+	return dockerContainerCollector{
+		section: section,
+		StatusDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "status_info"),
+			"Container status as reported by docker",
+			dockerContainerStatusLabelNames, nil,
+		),
+		RunningDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "running"),
+			"Whether the container is running (1) or not (0)",
+			dockerContainerLabelNames, nil,
+		),
+		OOMKilledDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "oom_killed"),
+			"Whether the container was killed for running out of memory (1) or not (0)",
+			dockerContainerLabelNames, nil,
+		),
+		ExitCodeDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "exit_code"),
+			"Exit code of the container's last run",
+			dockerContainerLabelNames, nil,
+		),
+		CpuDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "cpu_seconds_total"),
+			"CPU time consumed by the container",
+			dockerContainerCpuLabelNames, nil,
+		),
+		OnlineCpusDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "online_cpus"),
+			"Number of CPUs available to the container",
+			dockerContainerLabelNames, nil,
+		),
+		MemUsageDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "memory_usage_bytes"),
+			"Memory used by the container",
+			dockerContainerLabelNames, nil,
+		),
+		MemLimitDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "memory_limit_bytes"),
+			"Memory limit of the container",
+			dockerContainerLabelNames, nil,
+		),
+		MemCacheDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "memory_cache_bytes"),
+			"Page cache memory used by the container",
+			dockerContainerLabelNames, nil,
+		),
+		MemRssDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "memory_rss_bytes"),
+			"Anonymous memory used by the container",
+			dockerContainerLabelNames, nil,
+		),
+	}
+}
+
+// Update is only called when the plugin runs without piggyback, in which case
+// the containers can't be told apart.
+func (d dockerContainerCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	log.Debugf("Skipping '%s' section without piggybacked container", d.section)
+	return nil
+}
+
+func (d dockerContainerCollector) UpdatePiggyback(container string, unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	switch d.section {
+	case "docker_container_status":
+		var status dockerContainerStatus
+		if err := decodeDockerSection(unparsedStats, &status); err != nil {
+			log.Errorf("Unable to parse status of container '%s': %s", container, err)
+			return err
+		}
+		ch <- prometheus.MustNewConstMetric(
+			d.StatusDesc, prometheus.GaugeValue, 1, container, status.Status,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			d.RunningDesc, prometheus.GaugeValue, boolToFloat(status.Running), container,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			d.OOMKilledDesc, prometheus.GaugeValue, boolToFloat(status.OOMKilled), container,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			d.ExitCodeDesc, prometheus.GaugeValue, status.ExitCode, container,
+		)
+	case "docker_container_cpu":
+		var cpu dockerContainerCpu
+		if err := decodeDockerSection(unparsedStats, &cpu); err != nil {
+			log.Errorf("Unable to parse cpu stats of container '%s': %s", container, err)
+			return err
+		}
+		// docker reports cpu usage in nanoseconds
+		ch <- prometheus.MustNewConstMetric(
+			d.CpuDesc, prometheus.CounterValue, cpu.CpuUsage.UsageInUsermode/1e9, container, "user",
+		)
+		ch <- prometheus.MustNewConstMetric(
+			d.CpuDesc, prometheus.CounterValue, cpu.CpuUsage.UsageInKernelmode/1e9, container, "system",
+		)
+		ch <- prometheus.MustNewConstMetric(
+			d.OnlineCpusDesc, prometheus.GaugeValue, cpu.OnlineCpus, container,
+		)
+	case "docker_container_mem":
+		var mem dockerContainerMem
+		if err := decodeDockerSection(unparsedStats, &mem); err != nil {
+			log.Errorf("Unable to parse memory stats of container '%s': %s", container, err)
+			return err
+		}
+		ch <- prometheus.MustNewConstMetric(
+			d.MemUsageDesc, prometheus.GaugeValue, mem.Usage, container,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			d.MemLimitDesc, prometheus.GaugeValue, mem.Limit, container,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			d.MemCacheDesc, prometheus.GaugeValue, mem.Stats.Cache, container,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			d.MemRssDesc, prometheus.GaugeValue, mem.Stats.Rss, container,
+		)
+	}
+	return nil
+}
+
+// decodeDockerSection decodes the first JSON document of a section.
+func decodeDockerSection(unparsedStats *[]string, v interface{}) error {
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		if !strings.HasPrefix(stat, "{") {
+			continue
+		}
+		return json.Unmarshal([]byte(stat), v)
+	}
+	return errors.New("no JSON document found")
+}
@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"bytes"
+	"github.com/bverschueren/check_mk_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"io/ioutil"
+	"testing"
+)
+
+func TestSplitPiggyback(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	own, piggybacked := splitPiggyback(bytes.NewBuffer(content))
+
+	structuredStats := (*structureRawStats(own))
+	if _, ok := structuredStats["docker_container_status"]; ok {
+		t.Errorf("want piggybacked sections to be separated from the host's sections")
+	}
+	var info dockerNodeInfo
+	if err := decodeDockerSection(structuredStats["docker_node_info"], &info); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2.0, info.ContainersRunning; want != got {
+		t.Errorf("want %f running containers, got %f", want, got)
+	}
+
+	if want, got := 2, len(piggybacked); want != got {
+		t.Fatalf("want %d piggybacked hosts, got %d", want, got)
+	}
+	containerStats := (*structureRawStats(piggybacked["4c8fa1b2d3e4"]))
+	if want, got := 3, len(containerStats); want != got {
+		t.Errorf("want %d sections for container, got %d", want, got)
+	}
+	var mem dockerContainerMem
+	if err := decodeDockerSection(containerStats["docker_container_mem"], &mem); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 41943040.0, mem.Stats.Rss; want != got {
+		t.Errorf("want rss %f, got %f", want, got)
+	}
+
+	var status dockerContainerStatus
+	containerStats = (*structureRawStats(piggybacked["9e0d1c2b3a4f"]))
+	if err := decodeDockerSection(containerStats["docker_container_status"], &status); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 137.0, status.ExitCode; want != got || !status.OOMKilled {
+		t.Errorf("want exit code %f after OOM kill, got %f", want, got)
+	}
+}
+
+// outputCollector hands the agent output to the target's collectors when
+// gathered.
+type outputCollector struct {
+	mc     CheckMKCollector
+	output []byte
+}
+
+func (o outputCollector) Collect(ch chan<- prometheus.Metric) {
+	o.mc.collectOutput(bytes.NewBuffer(o.output), ch)
+}
+
+func (o outputCollector) Describe(ch chan<- *prometheus.Desc) {
+	// unchecked, as CheckMKCollector
+}
+
+func TestDockerPiggybackMetrics(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc, err := NewMKCheckCollector(config.Target{HostName: "dockerhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(outputCollector{mc, content})
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the values per metric and container
+	values := make(map[string]map[string]float64)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() != "container" {
+					continue
+				}
+				if _, ok := values[family.GetName()]; !ok {
+					values[family.GetName()] = make(map[string]float64)
+				}
+				values[family.GetName()][l.GetValue()] = m.GetGauge().GetValue() + m.GetCounter().GetValue()
+			}
+		}
+	}
+	for _, c := range []struct {
+		metric, container string
+		want              float64
+	}{
+		{"check_mk_docker_container_running", "4c8fa1b2d3e4", 1},
+		{"check_mk_docker_container_running", "9e0d1c2b3a4f", 0},
+		{"check_mk_docker_container_oom_killed", "9e0d1c2b3a4f", 1},
+		{"check_mk_docker_container_exit_code", "9e0d1c2b3a4f", 137},
+		{"check_mk_docker_container_memory_rss_bytes", "4c8fa1b2d3e4", 41943040},
+	} {
+		got, ok := values[c.metric][c.container]
+		if !ok || c.want != got {
+			t.Errorf("want %s of container %s %f, got %f (%v)", c.metric, c.container, c.want, got, ok)
+		}
+	}
+	if _, ok := values["check_mk_docker_container_memory_rss_bytes"]["9e0d1c2b3a4f"]; ok {
+		t.Error("want no memory metrics for the container without mem section")
+	}
+}
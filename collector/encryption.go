@@ -0,0 +1,138 @@
+package collector
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/pbkdf2"
+	"hash"
+)
+
+var (
+	decryptionFailedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "agent", "decryption_failed"),
+		"Whether the encrypted agent output could not be decrypted (1) or not (0)",
+		nil, nil,
+	)
+)
+
+const (
+	// the marker and salt length of OpenSSL's 'enc' output
+	opensslSaltedMarker = "Salted__"
+	opensslSaltLength   = 8
+
+	// the iterations of the PBKDF2 protocol version
+	pbkdf2Iterations = 10000
+)
+
+type decryptionError struct {
+	error
+}
+
+// fetchRawStats collects the agent output, decrypting it if needed. The
+// output of agent runs exiting non-zero is only returned, along with the
+// agentExitError, if the target parses partial output.
+func (mc CheckMKCollector) fetchRawStats() (*bytes.Buffer, error) {
+	rawStats, runErr := mc.collectRawStats()
+	if _, ok := runErr.(agentExitError); ok && mc.target.ParsePartialOutput {
+		log.Warnf("Parsing partial output of '%s': %s", mc.target.Name, runErr)
+	} else if runErr != nil {
+		return nil, runErr
+	}
+	passphrase, err := mc.target.ReadPassphrase()
+	if err != nil {
+		log.Errorf("Unable to read passphrase for '%s': %s", mc.target.HostName, err)
+		return nil, decryptionError{err}
+	}
+	decrypted, err := decryptAgentOutput(rawStats, passphrase)
+	if err != nil {
+		log.Errorf("Unable to decrypt output of '%s': %s", mc.target.HostName, err)
+		return nil, decryptionError{err}
+	}
+	return decrypted, runErr
+}
+
+// decryptAgentOutput decrypts the output of agents with encryption enabled,
+// which prefix the output of 'openssl enc -aes-256-cbc' by a protocol
+// version:
+//
+//	00	key derived from the passphrase by MD5
+//	02	key derived from the passphrase by SHA256
+//	03	key derived from the passphrase by PBKDF2 with SHA256
+//
+// Unencrypted output is returned as is, as is any output if no passphrase is
+// configured: the sections' parsers then skip what they don't recognise.
+func decryptAgentOutput(raw *bytes.Buffer, passphrase string) (*bytes.Buffer, error) {
+	data := raw.Bytes()
+	if passphrase == "" || len(data) < 2 || bytes.HasPrefix(data, []byte("<<<")) {
+		return raw, nil
+	}
+	version, data := string(data[:2]), data[2:]
+
+	var derive func(salt []byte) (key, iv []byte)
+	switch version {
+	case "00":
+		derive = func(salt []byte) ([]byte, []byte) {
+			return evpBytesToKey(md5.New, []byte(passphrase), salt)
+		}
+	case "02":
+		derive = func(salt []byte) ([]byte, []byte) {
+			return evpBytesToKey(sha256.New, []byte(passphrase), salt)
+		}
+	case "03":
+		derive = func(salt []byte) ([]byte, []byte) {
+			keyIV := pbkdf2.Key([]byte(passphrase), salt, pbkdf2Iterations, 32+aes.BlockSize, sha256.New)
+			return keyIV[:32], keyIV[32:]
+		}
+	default:
+		return nil, fmt.Errorf("unsupported agent output protocol version '%s'", version)
+	}
+	if !bytes.HasPrefix(data, []byte(opensslSaltedMarker)) || len(data) < len(opensslSaltedMarker)+opensslSaltLength {
+		return nil, fmt.Errorf("agent output lacks the salt of the encryption")
+	}
+	data = data[len(opensslSaltedMarker):]
+	salt, ciphertext := data[:opensslSaltLength], data[opensslSaltLength:]
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("agent output has an invalid length for AES-256-CBC")
+	}
+
+	key, iv := derive(salt)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	// a wrong passphrase shows as invalid PKCS#7 padding
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, fmt.Errorf("unable to decrypt agent output, wrong passphrase?")
+	}
+	for _, b := range plaintext[len(plaintext)-padding:] {
+		if int(b) != padding {
+			return nil, fmt.Errorf("unable to decrypt agent output, wrong passphrase?")
+		}
+	}
+	return bytes.NewBuffer(plaintext[:len(plaintext)-padding]), nil
+}
+
+// evpBytesToKey derives the AES-256 key and IV like OpenSSL's EVP_BytesToKey
+// with a single iteration.
+func evpBytesToKey(newHash func() hash.Hash, passphrase, salt []byte) ([]byte, []byte) {
+	var derived, prev []byte
+	for len(derived) < 32+aes.BlockSize {
+		h := newHash()
+		h.Write(prev)
+		h.Write(passphrase)
+		h.Write(salt)
+		prev = h.Sum(nil)
+		derived = append(derived, prev...)
+	}
+	return derived[:32], derived[32 : 32+aes.BlockSize]
+}
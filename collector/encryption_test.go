@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDecryptAgentOutput(t *testing.T) {
+	plain, err := ioutil.ReadFile("../testdata/check_mk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, version := range []string{"00", "02", "03"} {
+		encrypted, err := ioutil.ReadFile("../testdata/encrypted/agent_output." + version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypted, err := decryptAgentOutput(bytes.NewBuffer(encrypted), "s3cr3t")
+		if err != nil {
+			t.Errorf("want protocol version %s to decrypt, got %s", version, err)
+			continue
+		}
+		if !bytes.Equal(plain, decrypted.Bytes()) {
+			t.Errorf("want the agent output for protocol version %s, got %q", version, decrypted.String())
+		}
+
+		if _, err := decryptAgentOutput(bytes.NewBuffer(encrypted), "wrong"); err == nil {
+			t.Errorf("want an error for a wrong passphrase with protocol version %s", version)
+		}
+	}
+
+	decrypted, err := decryptAgentOutput(bytes.NewBuffer(plain), "s3cr3t")
+	if err != nil || !bytes.Equal(plain, decrypted.Bytes()) {
+		t.Errorf("want unencrypted output to be passed as is, got %s", err)
+	}
+
+	// e.g. a banner printed before the first section
+	banner := bytes.NewBufferString("Welcome to myhost\n<<<check_mk>>>\n")
+	if _, err := decryptAgentOutput(banner, ""); err != nil {
+		t.Errorf("want output to be passed as is without passphrase, got %s", err)
+	}
+	if _, err := decryptAgentOutput(bytes.NewBufferString("Welcome to myhost\n"), "s3cr3t"); err == nil {
+		t.Error("want an error for output that isn't encrypted, but a passphrase is configured")
+	}
+}
@@ -0,0 +1,93 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+const (
+	// the timeout of the agent run, unless configured per target
+	execTimeout = 60 * time.Second
+	// how long to wait for the output after the agent was killed
+	execWaitDelay = 5 * time.Second
+)
+
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGABRT: "ABRT",
+	syscall.SIGALRM: "ALRM",
+	syscall.SIGFPE:  "FPE",
+	syscall.SIGHUP:  "HUP",
+	syscall.SIGILL:  "ILL",
+	syscall.SIGINT:  "INT",
+	syscall.SIGKILL: "KILL",
+	syscall.SIGPIPE: "PIPE",
+	syscall.SIGQUIT: "QUIT",
+	syscall.SIGSEGV: "SEGV",
+	syscall.SIGTERM: "TERM",
+}
+
+// collectExecRawStats runs the agent command on the exporter's host, e.g. for
+// the host itself or containers sharing its network namespace. Stderr of the
+// command is logged. On timeout, the command is killed along with the plugins
+// it started, which run in its process group.
+func (mc CheckMKCollector) collectExecRawStats() (*bytes.Buffer, error) {
+	log.Debugf("Collecting stats from %s by running '%s'", mc.target.Name, mc.Command)
+	args, err := mc.localCommand()
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no command to run for target '%s'", mc.target.Name)
+	}
+	timeout := mc.target.Timeout
+	if timeout <= 0 {
+		timeout = execTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// children holding on to stdout don't block the run past the timeout
+	cmd.WaitDelay = execWaitDelay
+	cmd.Env = append(os.Environ(), mc.agentEnv()...)
+	cmd.Stdout = &stdoutBuf
+	cmd.Stderr = &stderrBuf
+
+	err = cmd.Run()
+	log.Trace("Raw check_mk stats: " + stdoutBuf.String())
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("'%s' timed out after %s", mc.Command, timeout)
+	} else if exitErr, ok := err.(*exec.ExitError); ok {
+		e := agentExitError{code: exitErr.ExitCode(), output: &stdoutBuf}
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			e.signal = signalName(status.Signal())
+		}
+		err = e
+	}
+	mc.logAgentRun(err, stderrBuf.String())
+	if err != nil {
+		if _, ok := err.(agentExitError); !ok {
+			return nil, err
+		}
+	}
+	return &stdoutBuf, err
+}
+
+// signalName returns the name of the signal like SSH reports it, e.g. 'TERM'.
+func signalName(sig syscall.Signal) string {
+	if name, ok := signalNames[sig]; ok {
+		return name
+	}
+	return sig.String()
+}
@@ -0,0 +1,146 @@
+package collector
+
+import (
+	"bytes"
+	"github.com/bverschueren/check_mk_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectExecRawStats(t *testing.T) {
+	output, err := ioutil.ReadFile("../testdata/check_mk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := CheckMKCollector{
+		target:  config.Target{Name: "localhost", Transport: "exec"},
+		Command: "cat ../testdata/check_mk",
+	}
+	raw, err := mc.collectRawStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(output, raw.Bytes()) {
+		t.Errorf("want the command's output, got %q", raw.String())
+	}
+
+	mc.Command = `sh -c 'cat "$1"' agent ../testdata/check_mk`
+	raw, err = mc.collectRawStats()
+	if err != nil || !bytes.Equal(output, raw.Bytes()) {
+		t.Errorf("want the output of the command with quoted arguments, got %v", err)
+	}
+
+	mc.Command = `sh -c 'cat ../testdata/check_mk`
+	if _, err := mc.collectRawStats(); err == nil {
+		t.Error("want an error for an unterminated quote")
+	}
+
+	mc.Command = "env"
+	mc.target.Env = map[string]string{"MK_CONFDIR": "/etc/check_mk"}
+	raw, err = mc.collectRawStats()
+	if err != nil || !strings.Contains(raw.String(), "MK_CONFDIR=/etc/check_mk\n") {
+		t.Errorf("want the configured environment, got %v", err)
+	}
+
+	mc.Command = "sleep 5"
+	mc.target.Timeout = 100 * time.Millisecond
+	start := time.Now()
+	if _, err := mc.collectRawStats(); err == nil || time.Since(start) > 2*time.Second {
+		t.Errorf("want the command to time out, got %v after %s", err, time.Since(start))
+	}
+
+	// plugins forked by the agent are killed as well
+	mc.Command = "sh -c 'sleep 5; echo done'"
+	start = time.Now()
+	if _, err := mc.collectRawStats(); err == nil || time.Since(start) > 2*time.Second {
+		t.Errorf("want the command and its children to time out, got %v after %s", err, time.Since(start))
+	}
+
+	mc.Command = "false"
+	if _, err := mc.collectRawStats(); err == nil {
+		t.Error("want an error for a failing command")
+	}
+
+	mc.Command = "/nonexistent/check_mk_agent"
+	if _, err := mc.collectRawStats(); err == nil {
+		t.Error("want an error for a missing command")
+	}
+}
+
+func TestCollectExecExitCode(t *testing.T) {
+	target := config.Target{Name: "localhost", Transport: "exec", Command: "sh ../testdata/exec/failing_agent"}
+	mc, err := NewMKCheckCollector(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = mc.collectRawStats()
+	if exitErr, ok := err.(agentExitError); !ok || exitErr.code != 2 || exitErr.output.Len() == 0 {
+		t.Errorf("want exit status 2 along with the output, got %v", err)
+	}
+
+	for _, partial := range []bool{false, true} {
+		mc.target.ParsePartialOutput = partial
+		ch := make(chan prometheus.Metric, 1000)
+		mc.Collect(ch)
+		close(ch)
+		metrics := 0
+		for m := range ch {
+			metrics++
+			if m.Desc() != exitCodeDesc {
+				continue
+			}
+			var metric dto.Metric
+			m.Write(&metric)
+			if want, got := 2.0, metric.GetGauge().GetValue(); want != got {
+				t.Errorf("want exit code %v, got %v", want, got)
+			}
+		}
+		if parsed := metrics > 1; parsed != partial {
+			t.Errorf("want partial output parsed %v, got %d metrics", partial, metrics)
+		}
+	}
+
+	mc.Command = "sh ../testdata/exec/killed_agent"
+	_, err = mc.collectRawStats()
+	if exitErr, ok := err.(agentExitError); !ok || exitErr.code != -1 || exitErr.signal != "TERM" {
+		t.Errorf("want the agent to be killed by TERM, got %v", err)
+	}
+}
+
+func TestCollectExecSudoEnv(t *testing.T) {
+	// a stand-in for sudo, resetting the environment but for the variables
+	// passed as arguments
+	bin, err := filepath.Abs("../testdata/exec/bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", bin+string(os.PathListSeparator)+path)
+
+	mc := CheckMKCollector{
+		target: config.Target{
+			Name:      "localhost",
+			Transport: "exec",
+			Sudo:      true,
+			Env:       map[string]string{"MK_CONFDIR": "/etc/check mk"},
+			Sections:  []string{"df", "mem"},
+		},
+		Command: "env",
+	}
+	raw, err := mc.collectRawStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range []string{"SUDO_USER=", "MK_CONFDIR=/etc/check mk\n", "CHECK_MK_EXPORTER_SECTIONS=df mem\n"} {
+		if !strings.Contains(raw.String(), env) {
+			t.Errorf("want %q in the environment of the agent run by sudo, got %q", env, raw.String())
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	outputAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "agent", "output_age_seconds"),
+		"Age of the spooled or pushed agent output",
+		nil, nil,
+	)
+
+	gzipMagic = []byte{0x1f, 0x8b}
+
+	// when the spooled or pushed output handled last per target was written
+	handledOutputs      = make(map[string]time.Time)
+	handledOutputsMutex sync.Mutex
+)
+
+// spoolFile returns the path of the target's spooled output, '<target>' or
+// '<target>.gz' in the spool directory.
+func (mc CheckMKCollector) spoolFile() string {
+	path := filepath.Join(mc.target.SpoolDirectory, mc.target.Name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if _, err := os.Stat(path + ".gz"); err == nil {
+			return path + ".gz"
+		}
+	}
+	return path
+}
+
+func spoolFileAge(path string) (time.Duration, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return time.Since(info.ModTime()), nil
+}
+
+// outputTime returns when the output of targets that don't serve it when
+// scraped was written.
+func (mc CheckMKCollector) outputTime() (time.Time, bool) {
+	switch mc.target.Transport {
+	case "push":
+		if output, ok := lookupPushedOutput(mc.target.Name); ok {
+			return output.received, true
+		}
+		if mc.target.SpoolDirectory == "" {
+			return time.Time{}, false
+		}
+	case "file":
+	default:
+		return time.Time{}, false
+	}
+	info, err := os.Stat(mc.spoolFile())
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
+
+// outputAge returns the age of the output of targets that don't serve it
+// when scraped.
+func (mc CheckMKCollector) outputAge() (time.Duration, bool) {
+	written, ok := mc.outputTime()
+	return time.Since(written), ok
+}
+
+// outputHandled tells whether the target's output was handled by a previous
+// scrape, as spooled and pushed output is until it's replaced, and remembers
+// it as handled.
+func (mc CheckMKCollector) outputHandled() bool {
+	written, ok := mc.outputTime()
+	if !ok {
+		return false
+	}
+	handledOutputsMutex.Lock()
+	defer handledOutputsMutex.Unlock()
+	handled, ok := handledOutputs[mc.target.Name]
+	handledOutputs[mc.target.Name] = written
+	return ok && handled.Equal(written)
+}
+
+// collectFileRawStats reads the agent output spooled by hosts that cannot be
+// reached, e.g. pushing it by rsync, gunzipping it if needed.
+func (mc CheckMKCollector) collectFileRawStats() (*bytes.Buffer, error) {
+	path := mc.spoolFile()
+	log.Debugf("Collecting stats from %s in '%s'", mc.target.Name, path)
+
+	age, err := spoolFileAge(path)
+	if err != nil {
+		log.Infof("Unable to collect stats from '%s': %s", mc.target.Name, err)
+		return nil, err
+	}
+	if mc.target.MaxAge > 0 && age > mc.target.MaxAge {
+		err := fmt.Errorf("'%s' is outdated, last modified %s ago", path, age.Truncate(time.Second))
+		log.Warnf("Unable to collect stats from '%s': %s", mc.target.Name, err)
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Infof("Unable to collect stats from '%s': %s", mc.target.Name, err)
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, gzipMagic) {
+		log.Trace("Raw check_mk stats: " + string(data))
+		return bytes.NewBuffer(data), nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	raw := new(bytes.Buffer)
+	if _, err := raw.ReadFrom(r); err != nil {
+		return nil, fmt.Errorf("unable to decompress '%s': %s", path, err)
+	}
+	log.Trace("Raw check_mk stats: " + raw.String())
+	return raw, nil
+}
@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/bverschueren/check_mk_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCollectFileRawStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output, err := ioutil.ReadFile("../testdata/check_mk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Write(output)
+	w.Close()
+	ioutil.WriteFile(filepath.Join(dir, "plain"), output, 0644)
+	ioutil.WriteFile(filepath.Join(dir, "compressed.gz"), compressed.Bytes(), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "outdated"), output, 0644)
+	lastHour := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, "outdated"), lastHour, lastHour)
+
+	for _, tc := range []struct {
+		name string
+		ok   bool
+	}{
+		{"plain", true},
+		{"compressed", true},
+		{"outdated", false},
+		{"missing", false},
+	} {
+		mc := CheckMKCollector{target: config.Target{
+			Name:           tc.name,
+			Transport:      "file",
+			SpoolDirectory: dir,
+			MaxAge:         10 * time.Minute,
+		}}
+		raw, err := mc.collectRawStats()
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("want an error for '%s'", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("want the spooled output of '%s', got %s", tc.name, err)
+			continue
+		}
+		if !bytes.Equal(output, raw.Bytes()) {
+			t.Errorf("want the spooled output of '%s', got %q", tc.name, raw.String())
+		}
+	}
+
+	mc := CheckMKCollector{target: config.Target{Name: "outdated", Transport: "file", SpoolDirectory: dir}}
+	ch := make(chan prometheus.Metric, 10)
+	mc.Collect(ch)
+	close(ch)
+	found := false
+	for m := range ch {
+		found = found || m.Desc() == outputAgeDesc
+	}
+	if !found {
+		t.Error("want the output age to be reported")
+	}
+}
+
+func TestCollectFileLogwatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer resetLogwatchCounts("spooled")
+
+	output, err := ioutil.ReadFile("../testdata/logwatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "spooled")
+	ioutil.WriteFile(path, output, 0644)
+	mc, err := NewMKCheckCollector(config.Target{Name: "spooled", Transport: "file", SpoolDirectory: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scrape := func() float64 {
+		ch := make(chan prometheus.Metric, 100)
+		mc.Collect(ch)
+		close(ch)
+		return logwatchCount("spooled", "/var/log/messages", "critical")
+	}
+	if want, got := 2.0, scrape(); want != got {
+		t.Errorf("want %f critical lines, got %f", want, got)
+	}
+	// until it's replaced, the output is only counted once
+	if want, got := 2.0, scrape(); want != got {
+		t.Errorf("want %f critical lines after scraping the same output again, got %f", want, got)
+	}
+	replaced := time.Now().Add(time.Minute)
+	os.Chtimes(path, replaced, replaced)
+	if want, got := 4.0, scrape(); want != got {
+		t.Errorf("want %f critical lines after the output was replaced, got %f", want, got)
+	}
+}
@@ -0,0 +1,255 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/bverschueren/check_mk_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	fileinfoLabelNames      = []string{"path"}
+	fileinfoGroupLabelNames = []string{"group"}
+
+	fileinfoGroups []config.FileinfoGroup
+)
+
+type fileinfoCollector struct {
+	SizeDesc           *prometheus.Desc
+	MtimeDesc          *prometheus.Desc
+	MissingDesc        *prometheus.Desc
+	GroupCountDesc     *prometheus.Desc
+	GroupSizeDesc      *prometheus.Desc
+	GroupOldestAgeDesc *prometheus.Desc
+	GroupNewestAgeDesc *prometheus.Desc
+}
+
+type fileinfoStats struct {
+	path        string
+	size, mtime float64
+	missing     bool
+}
+
+type fileinfoGroupStats struct {
+	name                     string
+	count, size              float64
+	oldestMtime, newestMtime float64
+}
+
+func init() {
+	registerCollector("fileinfo", NewFileinfoCollector)
+}
+
+// SetFileinfoGroups configures the groups the fileinfo entries are
+// aggregated into.
+func SetFileinfoGroups(groups []config.FileinfoGroup) error {
+	for _, g := range groups {
+		if g.Name == "" {
+			return fmt.Errorf("fileinfo group without name")
+		}
+		for _, patterns := range [][]string{g.Include, g.Exclude} {
+			for _, pattern := range patterns {
+				if _, err := filepath.Match(pattern, ""); err != nil {
+					return fmt.Errorf("invalid pattern '%s' for fileinfo group '%s': %s", pattern, g.Name, err)
+				}
+			}
+		}
+	}
+	fileinfoGroups = groups
+	return nil
+}
+
+func NewFileinfoCollector() (Collector, error) {
+	subsystem := "fileinfo"
+
+	SizeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "size_bytes"),
+		"File size",
+		fileinfoLabelNames, nil,
+	)
+	MtimeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "mtime_seconds"),
+		"File modification time",
+		fileinfoLabelNames, nil,
+	)
+	MissingDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "missing"),
+		"Whether the file is missing or can't be examined (1) or not (0)",
+		fileinfoLabelNames, nil,
+	)
+	GroupCountDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "group_files"),
+		"Number of files in the group",
+		fileinfoGroupLabelNames, nil,
+	)
+	GroupSizeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "group_size_bytes"),
+		"Total size of the files in the group",
+		fileinfoGroupLabelNames, nil,
+	)
+	GroupOldestAgeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "group_oldest_age_seconds"),
+		"Age of the oldest file in the group",
+		fileinfoGroupLabelNames, nil,
+	)
+	GroupNewestAgeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "group_newest_age_seconds"),
+		"Age of the newest file in the group",
+		fileinfoGroupLabelNames, nil,
+	)
+	return fileinfoCollector{
+		SizeDesc:           SizeDesc,
+		MtimeDesc:          MtimeDesc,
+		MissingDesc:        MissingDesc,
+		GroupCountDesc:     GroupCountDesc,
+		GroupSizeDesc:      GroupSizeDesc,
+		GroupOldestAgeDesc: GroupOldestAgeDesc,
+		GroupNewestAgeDesc: GroupNewestAgeDesc,
+	}, nil
+}
+
+func (f fileinfoCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	now, stats := f.parseStats(unparsedStats)
+
+	for _, s := range stats {
+		ch <- prometheus.MustNewConstMetric(
+			f.MissingDesc, prometheus.GaugeValue, boolToFloat(s.missing), s.path,
+		)
+		if s.missing {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			f.SizeDesc, prometheus.GaugeValue, s.size, s.path,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			f.MtimeDesc, prometheus.GaugeValue, s.mtime, s.path,
+		)
+	}
+
+	for _, g := range groupFileinfoStats(fileinfoGroups, stats) {
+		ch <- prometheus.MustNewConstMetric(
+			f.GroupCountDesc, prometheus.GaugeValue, g.count, g.name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			f.GroupSizeDesc, prometheus.GaugeValue, g.size, g.name,
+		)
+		if g.count == 0 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			f.GroupOldestAgeDesc, prometheus.GaugeValue, now-g.oldestMtime, g.name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			f.GroupNewestAgeDesc, prometheus.GaugeValue, now-g.newestMtime, g.name,
+		)
+	}
+	return nil
+}
+
+// parseStats handles both fileinfo formats, which start with the agent's
+// current time. The legacy format lists 'path|size|mtime' or 'path|missing|now':
+//
+//	1565610130
+//	/var/backup/db.dump|1048576|1565606530
+//	/var/backup/old.dump|missing|1565610130
+//
+// while newer agents describe the columns in a [[[header]]] subsection:
+//
+//	1565610130
+//	[[[header]]]
+//	name|status|size|time
+//	[[[content]]]
+//	/var/backup/db.dump|ok|1048576|1565606530
+//	/var/backup/old.dump|missing
+func (f fileinfoCollector) parseStats(unparsedStats *[]string) (float64, []fileinfoStats) {
+
+	stats := []fileinfoStats{}
+	seen := make(map[string]struct{})
+	var now float64
+	var columns []string
+	inHeader := false
+
+	for i, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		if i == 0 {
+			now, _ = strconv.ParseFloat(strings.TrimSpace(stat), 64)
+			continue
+		}
+		switch stat {
+		case "[[[header]]]":
+			inHeader = true
+			continue
+		case "[[[content]]]":
+			inHeader = false
+			continue
+		}
+		fields := strings.Split(stat, "|")
+		if inHeader {
+			columns = fields
+			continue
+		}
+
+		var s fileinfoStats
+		if columns != nil {
+			values := make(map[string]string)
+			for j, column := range columns {
+				if j < len(fields) {
+					values[column] = fields[j]
+				}
+			}
+			s.path = values["name"]
+			s.missing = values["status"] != "ok"
+			s.size, _ = strconv.ParseFloat(values["size"], 64)
+			s.mtime, _ = strconv.ParseFloat(values["time"], 64)
+		} else {
+			if len(fields) < 3 {
+				log.Debugf("Skipping '%s'", stat)
+				continue
+			}
+			s.path = fields[0]
+			s.missing = fields[1] == "missing"
+			s.size, _ = strconv.ParseFloat(fields[1], 64)
+			s.mtime, _ = strconv.ParseFloat(fields[2], 64)
+		}
+		if _, ok := seen[s.path]; ok || s.path == "" {
+			continue
+		}
+		seen[s.path] = struct{}{}
+		stats = append(stats, s)
+	}
+	return now, stats
+}
+
+func groupFileinfoStats(groups []config.FileinfoGroup, stats []fileinfoStats) []fileinfoGroupStats {
+	matches := func(patterns []string, path string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := filepath.Match(pattern, path); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	groupStats := []fileinfoGroupStats{}
+	for _, g := range groups {
+		gs := fileinfoGroupStats{name: g.Name}
+		for _, s := range stats {
+			if s.missing || !matches(g.Include, s.path) || matches(g.Exclude, s.path) {
+				continue
+			}
+			if gs.count == 0 || s.mtime < gs.oldestMtime {
+				gs.oldestMtime = s.mtime
+			}
+			if gs.count == 0 || s.mtime > gs.newestMtime {
+				gs.newestMtime = s.mtime
+			}
+			gs.count++
+			gs.size += s.size
+		}
+		groupStats = append(groupStats, gs)
+	}
+	return groupStats
+}
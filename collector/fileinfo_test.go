@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"bytes"
+	"github.com/bverschueren/check_mk_exporter/config"
+	"io/ioutil"
+	"testing"
+)
+
+func readFileinfoStats(t *testing.T, path string) (float64, []fileinfoStats) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+	return fileinfoCollector{}.parseStats(structuredStats["fileinfo"])
+}
+
+func TestFileinfoParseLegacyStats(t *testing.T) {
+	now, stats := readFileinfoStats(t, "../testdata/fileinfo/legacy")
+	if want, got := 1565610130.0, now; want != got {
+		t.Errorf("want agent time %f, got %f", want, got)
+	}
+	if want, got := 4, len(stats); want != got {
+		t.Fatalf("want %d files, got %d", want, got)
+	}
+	if want, got := (fileinfoStats{path: "/var/backup/db.dump", size: 1048576, mtime: 1565606530}), stats[0]; want != got {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+	if want, got := "/var/backup/old.dump", stats[2].path; want != got || !stats[2].missing {
+		t.Errorf("want %s to be missing, got %+v", want, stats[2])
+	}
+}
+
+func TestFileinfoParseHeaderStats(t *testing.T) {
+	now, stats := readFileinfoStats(t, "../testdata/fileinfo/header")
+	if want, got := 1565610130.0, now; want != got {
+		t.Errorf("want agent time %f, got %f", want, got)
+	}
+	if want, got := 4, len(stats); want != got {
+		t.Fatalf("want %d files, got %d", want, got)
+	}
+	if want, got := (fileinfoStats{path: "/var/backup/db2.dump", size: 4096, mtime: 1565599330}), stats[1]; want != got {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+	for _, s := range stats[2:] {
+		if !s.missing {
+			t.Errorf("want %s to be missing", s.path)
+		}
+	}
+}
+
+func TestGroupFileinfoStats(t *testing.T) {
+	_, stats := readFileinfoStats(t, "../testdata/fileinfo/legacy")
+	groups := groupFileinfoStats([]config.FileinfoGroup{
+		{Name: "backups", Include: []string{"/var/backup/*.dump"}, Exclude: []string{"/var/backup/test*"}},
+		{Name: "all", Include: []string{"/var/*/*"}},
+		{Name: "none", Include: []string{"/srv/*"}},
+	}, stats)
+
+	if want, got := 3, len(groups); want != got {
+		t.Fatalf("want %d groups, got %d", want, got)
+	}
+	// the missing old.dump and the excluded test.dump don't count
+	if want, got := (fileinfoGroupStats{name: "backups", count: 1, size: 1048576, oldestMtime: 1565606530, newestMtime: 1565606530}), groups[0]; want != got {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+	if want, got := (fileinfoGroupStats{name: "all", count: 3, size: 1048576 + 2048 + 524288, oldestMtime: 1565596130, newestMtime: 1565610100}), groups[1]; want != got {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+	if want, got := (fileinfoGroupStats{name: "none"}), groups[2]; want != got {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,18 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectMetrics runs the collector's Update on the section's lines,
+// returning the metrics it sends.
+func collectMetrics(c Collector, stats *[]string) ([]prometheus.Metric, error) {
+	ch := make(chan prometheus.Metric, 100)
+	err := c.Update(stats, ch)
+	close(ch)
+	metrics := []prometheus.Metric{}
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+	return metrics, err
+}
@@ -0,0 +1,380 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+var (
+	inventoryOSLabelNames     = []string{"name", "version", "id", "pretty_name"}
+	inventoryKernelLabelNames = []string{"release", "arch"}
+	inventoryCPULabelNames    = []string{"model", "vendor"}
+	inventoryBIOSLabelNames   = []string{"vendor", "version", "date"}
+	inventorySystemLabelNames = []string{"manufacturer", "product", "serial_number"}
+)
+
+// Inventory holds the hardware and software inventory reported by the
+// mk_inventory plugin.
+type Inventory struct {
+	OS       InventoryOS        `json:"os"`
+	Kernel   InventoryKernel    `json:"kernel"`
+	CPU      InventoryCPU       `json:"cpu"`
+	BIOS     InventoryBIOS      `json:"bios"`
+	System   InventorySystem    `json:"system"`
+	Packages []InventoryPackage `json:"packages"`
+}
+
+type InventoryOS struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	ID         string `json:"id"`
+	PrettyName string `json:"pretty_name"`
+}
+
+type InventoryKernel struct {
+	Release string `json:"release"`
+	Arch    string `json:"arch"`
+}
+
+type InventoryCPU struct {
+	Model   string `json:"model"`
+	Vendor  string `json:"vendor"`
+	Threads int    `json:"threads"`
+}
+
+type InventoryBIOS struct {
+	Vendor  string `json:"vendor"`
+	Version string `json:"version"`
+	Date    string `json:"date"`
+}
+
+type InventorySystem struct {
+	Manufacturer string `json:"manufacturer"`
+	Product      string `json:"product"`
+	SerialNumber string `json:"serial_number"`
+}
+
+type InventoryPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Arch    string `json:"arch"`
+	Type    string `json:"type"`
+	Summary string `json:"summary,omitempty"`
+}
+
+// inventoryCollector exports the inventory sections as _info metrics.
+type inventoryCollector struct {
+	section     string
+	OSDesc      *prometheus.Desc
+	KernelDesc  *prometheus.Desc
+	CPUDesc     *prometheus.Desc
+	CPUThreads  *prometheus.Desc
+	BIOSDesc    *prometheus.Desc
+	SystemDesc  *prometheus.Desc
+	PackageDesc *prometheus.Desc
+}
+
+func init() {
+	registerCollector("lnx_distro", NewLnxDistroCollector)
+	registerCollector("lnx_uname", NewLnxUnameCollector)
+	registerCollector("lnx_cpuinfo", NewLnxCpuinfoCollector)
+	registerCollector("dmidecode", NewDmidecodeCollector)
+	registerCollector("lnx_packages", NewLnxPackagesCollector)
+}
+
+func NewLnxDistroCollector() (Collector, error) {
+	return newInventoryCollector("lnx_distro"), nil
+}
+
+func NewLnxUnameCollector() (Collector, error) {
+	return newInventoryCollector("lnx_uname"), nil
+}
+
+func NewLnxCpuinfoCollector() (Collector, error) {
+	return newInventoryCollector("lnx_cpuinfo"), nil
+}
+
+func NewDmidecodeCollector() (Collector, error) {
+	return newInventoryCollector("dmidecode"), nil
+}
+
+func NewLnxPackagesCollector() (Collector, error) {
+	return newInventoryCollector("lnx_packages"), nil
+}
+
+func newInventoryCollector(section string) inventoryCollector {
+	subsystem := "inventory"
+
+	return inventoryCollector{
+		section: section,
+		OSDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "os_info"),
+			"Operating system release, from /etc/os-release",
+			inventoryOSLabelNames, nil,
+		),
+		KernelDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "kernel_info"),
+			"Kernel release and architecture",
+			inventoryKernelLabelNames, nil,
+		),
+		CPUDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "cpu_info"),
+			"CPU model and vendor",
+			inventoryCPULabelNames, nil,
+		),
+		CPUThreads: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "cpu_threads"),
+			"Number of logical processors",
+			nil, nil,
+		),
+		BIOSDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "bios_info"),
+			"BIOS vendor, version and release date",
+			inventoryBIOSLabelNames, nil,
+		),
+		SystemDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "system_info"),
+			"System manufacturer, product name and serial number",
+			inventorySystemLabelNames, nil,
+		),
+		PackageDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "packages"),
+			"Number of installed packages",
+			nil, nil,
+		),
+	}
+}
+
+func (i inventoryCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	var inv Inventory
+	parseInventorySection(i.section, unparsedStats, &inv)
+
+	switch i.section {
+	case "lnx_distro":
+		ch <- prometheus.MustNewConstMetric(
+			i.OSDesc, prometheus.GaugeValue, 1,
+			inv.OS.Name, inv.OS.Version, inv.OS.ID, inv.OS.PrettyName,
+		)
+	case "lnx_uname":
+		ch <- prometheus.MustNewConstMetric(
+			i.KernelDesc, prometheus.GaugeValue, 1, inv.Kernel.Release, inv.Kernel.Arch,
+		)
+	case "lnx_cpuinfo":
+		ch <- prometheus.MustNewConstMetric(
+			i.CPUDesc, prometheus.GaugeValue, 1, inv.CPU.Model, inv.CPU.Vendor,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			i.CPUThreads, prometheus.GaugeValue, float64(inv.CPU.Threads),
+		)
+	case "dmidecode":
+		ch <- prometheus.MustNewConstMetric(
+			i.BIOSDesc, prometheus.GaugeValue, 1, inv.BIOS.Vendor, inv.BIOS.Version, inv.BIOS.Date,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			i.SystemDesc, prometheus.GaugeValue, 1,
+			inv.System.Manufacturer, inv.System.Product, inv.System.SerialNumber,
+		)
+	case "lnx_packages":
+		ch <- prometheus.MustNewConstMetric(
+			i.PackageDesc, prometheus.GaugeValue, float64(len(inv.Packages)),
+		)
+	}
+	return nil
+}
+
+// Inventory collects the inventory sections of the target's agent output.
+func (mc CheckMKCollector) Inventory() (*Inventory, error) {
+	rawStats, err := mc.fetchRawStats()
+	if rawStats == nil {
+		return nil, err
+	}
+	ownStats, _ := splitPiggyback(rawStats)
+	structuredStats := structureRawStats(ownStats)
+	mc.mergeCachedSections(structuredStats, sectionExpiries(ownStats))
+	return parseInventory(structuredStats), nil
+}
+
+func parseInventory(structuredStats *map[string]*[]string) *Inventory {
+	inv := &Inventory{Packages: []InventoryPackage{}}
+	for _, section := range []string{"lnx_distro", "lnx_uname", "lnx_cpuinfo", "dmidecode", "lnx_packages"} {
+		if stats, ok := (*structuredStats)[section]; ok {
+			parseInventorySection(section, stats, inv)
+		}
+	}
+	return inv
+}
+
+func parseInventorySection(section string, unparsedStats *[]string, inv *Inventory) {
+	switch section {
+	case "lnx_distro":
+		inv.OS = parseLnxDistro(unparsedStats)
+	case "lnx_uname":
+		inv.Kernel = parseLnxUname(unparsedStats)
+	case "lnx_cpuinfo":
+		inv.CPU = parseLnxCpuinfo(unparsedStats)
+	case "dmidecode":
+		inv.BIOS, inv.System = parseDmidecode(unparsedStats)
+	case "lnx_packages":
+		inv.Packages = parseLnxPackages(unparsedStats)
+	}
+}
+
+// parseLnxDistro handles the release files, one per subsection with their
+// lines joined by '|':
+//
+//	[[[/etc/os-release]]]
+//	NAME="Ubuntu"|VERSION="18.04.3 LTS (Bionic Beaver)"|ID=ubuntu|VERSION_ID="18.04"
+//
+// Without /etc/os-release, the first line of another release file is used
+// as the pretty name.
+func parseLnxDistro(unparsedStats *[]string) InventoryOS {
+	var release, fallback InventoryOS
+	for _, file := range splitSubsections(unparsedStats, "") {
+		if len(file.lines) == 0 {
+			continue
+		}
+		log.Tracef("[raw-structured] %s", file.lines[0])
+		values := strings.Split(file.lines[0], "|")
+		if file.name != "/etc/os-release" {
+			if fallback.PrettyName == "" {
+				fallback.PrettyName = values[0]
+			}
+			continue
+		}
+		for _, value := range values {
+			parts := strings.SplitN(value, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			v := strings.Trim(parts[1], `"'`)
+			switch parts[0] {
+			case "NAME":
+				release.Name = v
+			case "VERSION_ID":
+				release.Version = v
+			case "ID":
+				release.ID = v
+			case "PRETTY_NAME":
+				release.PrettyName = v
+			}
+		}
+	}
+	if release == (InventoryOS{}) {
+		return fallback
+	}
+	return release
+}
+
+// parseLnxUname handles 'uname -m' and 'uname -r' output on separate lines.
+func parseLnxUname(unparsedStats *[]string) InventoryKernel {
+	var kernel InventoryKernel
+	lines := *unparsedStats
+	if len(lines) > 0 {
+		kernel.Arch = strings.TrimSpace(lines[0])
+	}
+	if len(lines) > 1 {
+		kernel.Release = strings.TrimSpace(lines[1])
+	}
+	return kernel
+}
+
+// parseLnxCpuinfo handles /proc/cpuinfo, with one 'processor:<n>' line per
+// logical processor.
+func parseLnxCpuinfo(unparsedStats *[]string) InventoryCPU {
+	var cpu InventoryCPU
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		parts := strings.SplitN(stat, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		switch key {
+		case "processor":
+			cpu.Threads++
+		case "model name":
+			cpu.Model = value
+		case "vendor_id":
+			cpu.Vendor = value
+		}
+	}
+	return cpu
+}
+
+// parseDmidecode handles 'dmidecode -q' output with its tabs replaced by ':':
+//
+//	BIOS Information
+//	:Vendor: Dell Inc.
+//	:Version: 2.9.0
+//	System Information
+//	:Serial Number: ABC1234
+func parseDmidecode(unparsedStats *[]string) (InventoryBIOS, InventorySystem) {
+	var bios InventoryBIOS
+	var system InventorySystem
+	group := ""
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		if !strings.HasPrefix(stat, ":") {
+			group = strings.TrimSpace(stat)
+			continue
+		}
+		if strings.HasPrefix(stat, "::") {
+			// values spanning multiple lines
+			continue
+		}
+		parts := strings.SplitN(stat[1:], ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		switch group + "/" + key {
+		case "BIOS Information/Vendor":
+			bios.Vendor = value
+		case "BIOS Information/Version":
+			bios.Version = value
+		case "BIOS Information/Release Date":
+			bios.Date = value
+		case "System Information/Manufacturer":
+			system.Manufacturer = value
+		case "System Information/Product Name":
+			system.Product = value
+		case "System Information/Serial Number":
+			system.SerialNumber = value
+		}
+	}
+	return bios, system
+}
+
+// parseLnxPackages handles the package lists of dpkg, separated by '|' and
+// including the package status, and rpm, separated by tabs:
+//
+//	bash|4.4.18-2ubuntu1.2|amd64|deb|-|GNU Bourne Again SHell|install ok installed
+//	bash	4.2.46	x86_64	rpm	34.el7
+func parseLnxPackages(unparsedStats *[]string) []InventoryPackage {
+	packages := []InventoryPackage{}
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		sep := "\t"
+		if strings.Contains(stat, "|") {
+			sep = "|"
+		}
+		fields := strings.Split(stat, sep)
+		if len(fields) < 4 {
+			continue
+		}
+		if len(fields) >= 7 && !strings.HasSuffix(fields[6], " installed") {
+			// removed packages with their configuration files left behind
+			continue
+		}
+		p := InventoryPackage{Name: fields[0], Version: fields[1], Arch: fields[2], Type: fields[3]}
+		if len(fields) >= 5 && fields[4] != "-" && fields[4] != "" {
+			p.Version += "-" + fields[4]
+		}
+		if len(fields) >= 6 {
+			p.Summary = fields[5]
+		}
+		packages = append(packages, p)
+	}
+	return packages
+}
@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestParseInventory(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/inventory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv := parseInventory(structureRawStats(bytes.NewBuffer(content)))
+
+	if want, got := (InventoryOS{"Ubuntu", "18.04", "ubuntu", "Ubuntu 18.04.3 LTS"}), inv.OS; want != got {
+		t.Errorf("want os %+v, got %+v", want, got)
+	}
+	if want, got := (InventoryKernel{"4.15.0-55-generic", "x86_64"}), inv.Kernel; want != got {
+		t.Errorf("want kernel %+v, got %+v", want, got)
+	}
+	if want, got := 2, inv.CPU.Threads; want != got {
+		t.Errorf("want %d threads, got %d", want, got)
+	}
+	if want, got := "2.9.0", inv.BIOS.Version; want != got {
+		t.Errorf("want bios version %s, got %s", want, got)
+	}
+	if want, got := "ABC1234", inv.System.SerialNumber; want != got {
+		t.Errorf("want serial number %s, got %s", want, got)
+	}
+	if want, got := 2, len(inv.Packages); want != got {
+		t.Fatalf("want %d installed packages, got %d", want, got)
+	}
+	if want, got := "Secure Sockets Layer toolkit", inv.Packages[1].Summary; want != got {
+		t.Errorf("want summary %s, got %s", want, got)
+	}
+}
+
+func TestParseLnxPackagesRpm(t *testing.T) {
+	lines := []string{"bash\t4.2.46\tx86_64\trpm\t34.el7"}
+	packages := parseLnxPackages(&lines)
+	if want, got := 1, len(packages); want != got {
+		t.Fatalf("want %d packages, got %d", want, got)
+	}
+	if want, got := "4.2.46-34.el7", packages[0].Version; want != got {
+		t.Errorf("want version %s, got %s", want, got)
+	}
+}
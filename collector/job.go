@@ -0,0 +1,211 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	jobLabelNames = []string{"job"}
+
+	// mk-job writes to '<job>.<pid>running' while the job is in progress
+	jobRunningMarker = regexp.MustCompile(`^(.+)\.\d+running$`)
+)
+
+type jobCollector struct {
+	ExitCodeDesc         *prometheus.Desc
+	StartTimeDesc        *prometheus.Desc
+	DurationDesc         *prometheus.Desc
+	UserTimeDesc         *prometheus.Desc
+	SystemTimeDesc       *prometheus.Desc
+	MaxRssDesc           *prometheus.Desc
+	RunningDesc          *prometheus.Desc
+	RunningStartTimeDesc *prometheus.Desc
+}
+
+type jobStats struct {
+	name                                                string
+	startTime, exitCode, realTime, userTime, systemTime float64
+	maxRss                                              float64
+	completed, running                                  bool
+	runningStartTime                                    float64
+}
+
+func init() {
+	registerCollector("job", NewJobCollector)
+}
+
+func NewJobCollector() (Collector, error) {
+	subsystem := "job"
+
+	ExitCodeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "exit_code"),
+		"Exit code of the last completed run",
+		jobLabelNames, nil,
+	)
+	StartTimeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "last_start_timestamp_seconds"),
+		"Start time of the last completed run",
+		jobLabelNames, nil,
+	)
+	DurationDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "duration_seconds"),
+		"Wall clock duration of the last completed run",
+		jobLabelNames, nil,
+	)
+	UserTimeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "user_cpu_seconds"),
+		"User CPU time of the last completed run",
+		jobLabelNames, nil,
+	)
+	SystemTimeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "system_cpu_seconds"),
+		"System CPU time of the last completed run",
+		jobLabelNames, nil,
+	)
+	MaxRssDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "max_rss_bytes"),
+		"Maximum resident set size of the last completed run",
+		jobLabelNames, nil,
+	)
+	RunningDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "running"),
+		"Whether the job is currently running (1) or not (0)",
+		jobLabelNames, nil,
+	)
+	RunningStartTimeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "running_start_timestamp_seconds"),
+		"Start time of the run currently in progress",
+		jobLabelNames, nil,
+	)
+	return jobCollector{
+		ExitCodeDesc:         ExitCodeDesc,
+		StartTimeDesc:        StartTimeDesc,
+		DurationDesc:         DurationDesc,
+		UserTimeDesc:         UserTimeDesc,
+		SystemTimeDesc:       SystemTimeDesc,
+		MaxRssDesc:           MaxRssDesc,
+		RunningDesc:          RunningDesc,
+		RunningStartTimeDesc: RunningStartTimeDesc,
+	}, nil
+}
+
+func (j jobCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	stats := j.parseStats(unparsedStats)
+
+	for _, s := range stats {
+		ch <- prometheus.MustNewConstMetric(
+			j.RunningDesc, prometheus.GaugeValue, boolToFloat(s.running), s.name,
+		)
+		if s.running {
+			ch <- prometheus.MustNewConstMetric(
+				j.RunningStartTimeDesc, prometheus.GaugeValue, s.runningStartTime, s.name,
+			)
+		}
+		if !s.completed {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			j.ExitCodeDesc, prometheus.GaugeValue, s.exitCode, s.name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			j.StartTimeDesc, prometheus.GaugeValue, s.startTime, s.name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			j.DurationDesc, prometheus.GaugeValue, s.realTime, s.name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			j.UserTimeDesc, prometheus.GaugeValue, s.userTime, s.name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			j.SystemTimeDesc, prometheus.GaugeValue, s.systemTime, s.name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			j.MaxRssDesc, prometheus.GaugeValue, s.maxRss, s.name,
+		)
+	}
+	return nil
+}
+
+// parseStats handles the status files written by mk-job, each introduced by
+// a '==> <job> <==' line:
+//
+//	==> backup <==
+//	start_time 1565610130
+//	exit_code 0
+//	real_time 1:02.35
+//	user_time 0.41
+//	system_time 0.12
+//	max_res_kbytes 10340
+//
+// A job in progress shows up as '==> backup.4711running <==' with only its
+// start time, next to the status of its previous run.
+func (j jobCollector) parseStats(unparsedStats *[]string) []jobStats {
+
+	stats := []jobStats{}
+	index := make(map[string]int)
+	var cur *jobStats
+	running := false
+
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		if strings.HasPrefix(stat, "==> ") && strings.HasSuffix(stat, " <==") {
+			name := strings.TrimSuffix(strings.TrimPrefix(stat, "==> "), " <==")
+			running = false
+			if match := jobRunningMarker.FindStringSubmatch(name); match != nil {
+				name = match[1]
+				running = true
+			}
+			if _, ok := index[name]; !ok {
+				index[name] = len(stats)
+				stats = append(stats, jobStats{name: name})
+			}
+			cur = &stats[index[name]]
+			if running {
+				cur.running = true
+			}
+			continue
+		}
+		fields := strings.Fields(stat)
+		if cur == nil || len(fields) != 2 {
+			continue
+		}
+		if running {
+			if fields[0] == "start_time" {
+				cur.runningStartTime, _ = strconv.ParseFloat(fields[1], 64)
+			}
+			continue
+		}
+		switch fields[0] {
+		case "start_time":
+			cur.startTime, _ = strconv.ParseFloat(fields[1], 64)
+		case "exit_code":
+			cur.exitCode, _ = strconv.ParseFloat(fields[1], 64)
+			cur.completed = true
+		case "real_time":
+			cur.realTime = parseJobDuration(fields[1])
+		case "user_time":
+			cur.userTime, _ = strconv.ParseFloat(fields[1], 64)
+		case "system_time":
+			cur.systemTime, _ = strconv.ParseFloat(fields[1], 64)
+		case "max_res_kbytes":
+			maxRss, _ := strconv.ParseFloat(fields[1], 64)
+			cur.maxRss = maxRss * 1024
+		}
+	}
+	return stats
+}
+
+// parseJobDuration converts GNU time's elapsed time, '[hours:]minutes:seconds',
+// into seconds.
+func parseJobDuration(elapsed string) float64 {
+	seconds := 0.0
+	for _, part := range strings.Split(elapsed, ":") {
+		value, _ := strconv.ParseFloat(part, 64)
+		seconds = seconds*60 + value
+	}
+	return seconds
+}
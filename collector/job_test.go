@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestJobParseStats(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+
+	stats := jobCollector{}.parseStats(structuredStats["job"])
+	if want, got := 2, len(stats); want != got {
+		t.Fatalf("want %d jobs, got %d", want, got)
+	}
+
+	backup := stats[0]
+	if !backup.running || !backup.completed {
+		t.Errorf("want backup to be running with a previous completed run, got %+v", backup)
+	}
+	if want, got := 1565606530.0, backup.startTime; want != got {
+		t.Errorf("want start time %f, got %f", want, got)
+	}
+	if want, got := 1565610130.0, backup.runningStartTime; want != got {
+		t.Errorf("want running start time %f, got %f", want, got)
+	}
+	if want, got := 62.35, backup.realTime; want != got {
+		t.Errorf("want real time %f, got %f", want, got)
+	}
+
+	logrotate := stats[1]
+	if logrotate.running {
+		t.Errorf("want logrotate not to be running")
+	}
+	if want, got := 3660.0, logrotate.realTime; want != got {
+		t.Errorf("want real time %f, got %f", want, got)
+	}
+	if want, got := 1.0, logrotate.exitCode; want != got {
+		t.Errorf("want exit code %f, got %f", want, got)
+	}
+}
@@ -0,0 +1,274 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// the clock ticks of Solaris' kstat counters
+	solarisHZ = 100
+	// the statistics clock of FreeBSD, unless kern.clockrate says otherwise
+	freebsdStatHZ = 128
+)
+
+var (
+	kernelCpuLabelNames = []string{"mode"}
+
+	// the CPU time counters of Solaris' cpu_stat kstats
+	kstatCpuModes = map[string]string{"user": "user", "kernel": "system", "idle": "idle", "wait": "iowait"}
+
+	// the CPU time columns of FreeBSD's kern.cp_time
+	freebsdCpuModes = []string{"user", "nice", "system", "interrupt", "idle"}
+
+	freebsdStatHZField = regexp.MustCompile(`stathz = (\d+)`)
+
+	// the CPU columns of AIX' vmstat
+	vmstatAixCpuModes = map[int]string{13: "user", 14: "system", 15: "idle", 16: "iowait"}
+)
+
+// kernelCollector exports the kernel sections of the Solaris and FreeBSD
+// agents as a single check_mk_kernel_* metric family.
+type kernelCollector struct {
+	format              string
+	CpuDesc             *prometheus.Desc
+	ContextSwitchesDesc *prometheus.Desc
+	ForksDesc           *prometheus.Desc
+	MajorFaultsDesc     *prometheus.Desc
+}
+
+type kernelStats struct {
+	cpu                                 map[string]float64
+	contextSwitches, forks, majorFaults float64
+	hasContextSwitches                  bool
+	hasForks                            bool
+	hasMajorFaults                      bool
+}
+
+type vmstatAixCollector struct {
+	CpuDesc      *prometheus.Desc
+	RunnableDesc *prometheus.Desc
+	BlockedDesc  *prometheus.Desc
+}
+
+func init() {
+	registerOSCollector("solaris", "kernel", NewSolarisKernelCollector)
+	registerOSCollector("freebsd", "kernel", NewFreebsdKernelCollector)
+	registerOSCollector("aix", "vmstat_aix", NewVmstatAixCollector)
+}
+
+// NewSolarisKernelCollector handles the cpu_stat kstats of the Solaris agent.
+func NewSolarisKernelCollector() (Collector, error) {
+	return newKernelCollector("solaris"), nil
+}
+
+// NewFreebsdKernelCollector handles the sysctl counters of the FreeBSD agent.
+func NewFreebsdKernelCollector() (Collector, error) {
+	return newKernelCollector("freebsd"), nil
+}
+
+func newKernelCollector(format string) kernelCollector {
+	subsystem := "kernel"
+
+	CpuDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "cpu_seconds_total"),
+		"Time the CPUs spent in each mode",
+		kernelCpuLabelNames, nil,
+	)
+	ContextSwitchesDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "context_switches_total"),
+		"Number of context switches",
+		nil, nil,
+	)
+	ForksDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "forks_total"),
+		"Number of processes created",
+		nil, nil,
+	)
+	MajorFaultsDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "major_page_faults_total"),
+		"Number of major page faults",
+		nil, nil,
+	)
+	return kernelCollector{
+		format:              format,
+		CpuDesc:             CpuDesc,
+		ContextSwitchesDesc: ContextSwitchesDesc,
+		ForksDesc:           ForksDesc,
+		MajorFaultsDesc:     MajorFaultsDesc,
+	}
+}
+
+func (k kernelCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	var s kernelStats
+	switch k.format {
+	case "freebsd":
+		s = parseFreebsdKernelStats(unparsedStats)
+	default:
+		s = parseSolarisKernelStats(unparsedStats)
+	}
+
+	for mode, seconds := range s.cpu {
+		ch <- prometheus.MustNewConstMetric(k.CpuDesc, prometheus.CounterValue, seconds, mode)
+	}
+	if s.hasContextSwitches {
+		ch <- prometheus.MustNewConstMetric(k.ContextSwitchesDesc, prometheus.CounterValue, s.contextSwitches)
+	}
+	if s.hasForks {
+		ch <- prometheus.MustNewConstMetric(k.ForksDesc, prometheus.CounterValue, s.forks)
+	}
+	if s.hasMajorFaults {
+		ch <- prometheus.MustNewConstMetric(k.MajorFaultsDesc, prometheus.CounterValue, s.majorFaults)
+	}
+	return nil
+}
+
+// parseSolarisKernelStats sums the per CPU counters of 'kstat -p cpu_stat',
+// preceded by a timestamp:
+//
+//	1565610130
+//	cpu_stat:0:cpu_stat0:user	123456
+//	cpu_stat:0:cpu_stat0:pswitch	2450765
+func parseSolarisKernelStats(unparsedStats *[]string) kernelStats {
+	s := kernelStats{cpu: make(map[string]float64)}
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		fields := strings.Fields(stat)
+		if len(fields) != 2 {
+			continue
+		}
+		name := strings.Split(fields[0], ":")
+		if len(name) != 4 || name[0] != "cpu_stat" {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		if mode, ok := kstatCpuModes[name[3]]; ok {
+			s.cpu[mode] += value / solarisHZ
+			continue
+		}
+		switch name[3] {
+		case "pswitch":
+			s.contextSwitches += value
+			s.hasContextSwitches = true
+		case "sysfork", "sysvfork":
+			s.forks += value
+			s.hasForks = true
+		case "maj_fault":
+			s.majorFaults += value
+			s.hasMajorFaults = true
+		}
+	}
+	return s
+}
+
+// parseFreebsdKernelStats handles the sysctl output, preceded by a timestamp:
+//
+//	1565610130
+//	kern.clockrate: { hz = 1000, tick = 1000, profhz = 8128, stathz = 127 }
+//	kern.cp_time: 4117 0 1847 47 388227
+//	vm.stats.sys.v_swtch: 2450765
+//	vm.stats.vm.v_forks: 12345
+func parseFreebsdKernelStats(unparsedStats *[]string) kernelStats {
+	s := kernelStats{cpu: make(map[string]float64)}
+	statHZ := float64(freebsdStatHZ)
+	var cpTime []string
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		parts := strings.SplitN(stat, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch parts[0] {
+		case "kern.clockrate":
+			if match := freebsdStatHZField.FindStringSubmatch(value); match != nil {
+				if hz, err := strconv.ParseFloat(match[1], 64); err == nil && hz > 0 {
+					statHZ = hz
+				}
+			}
+		case "kern.cp_time":
+			cpTime = strings.Fields(value)
+		case "vm.stats.sys.v_swtch":
+			s.contextSwitches, s.hasContextSwitches = parseKernelCounter(value)
+		case "vm.stats.vm.v_forks":
+			s.forks, s.hasForks = parseKernelCounter(value)
+		}
+	}
+	// the statistics clock may only be listed after the CPU times
+	for i, mode := range freebsdCpuModes {
+		if i >= len(cpTime) {
+			break
+		}
+		if ticks, ok := parseKernelCounter(cpTime[i]); ok {
+			s.cpu[mode] = ticks / statHZ
+		}
+	}
+	return s
+}
+
+func parseKernelCounter(value string) (float64, bool) {
+	counter, err := strconv.ParseFloat(value, 64)
+	return counter, err == nil
+}
+
+func NewVmstatAixCollector() (Collector, error) {
+	subsystem := "vmstat_aix"
+
+	CpuDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "cpu_percent"),
+		"Percentage of CPU time spent in each mode since boot",
+		kernelCpuLabelNames, nil,
+	)
+	RunnableDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "runnable_threads"),
+		"Average number of runnable threads",
+		nil, nil,
+	)
+	BlockedDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "blocked_threads"),
+		"Average number of threads waiting for I/O",
+		nil, nil,
+	)
+	return vmstatAixCollector{
+		CpuDesc:      CpuDesc,
+		RunnableDesc: RunnableDesc,
+		BlockedDesc:  BlockedDesc,
+	}, nil
+}
+
+// Update handles the last line of AIX' vmstat output:
+//
+//	r b    avm    fre  re pi po fr sr cy  in  sy  cs us sy id wa   pc   ec
+//	2 1 1059617 3207203 0  0  0  0  0  0 123 4567 789 1  2 97  0 0.24 11.9
+func (v vmstatAixCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		fields := strings.Fields(stat)
+		if len(fields) < 17 {
+			continue
+		}
+		values := make([]float64, 17)
+		var err error
+		for i := range values {
+			if values[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(v.RunnableDesc, prometheus.GaugeValue, values[0])
+		ch <- prometheus.MustNewConstMetric(v.BlockedDesc, prometheus.GaugeValue, values[1])
+		for column, mode := range vmstatAixCpuModes {
+			ch <- prometheus.MustNewConstMetric(v.CpuDesc, prometheus.GaugeValue, values[column], mode)
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	hostLabelsMode = "info"
+
+	invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+type labelsCollector struct{}
+
+func init() {
+	registerCollector("labels", NewLabelsCollector)
+}
+
+// SetHostLabelsMode configures whether the host labels are exported as a
+// single info series ('info'), attached to every metric of the scrape
+// ('attach') or ignored ('none').
+func SetHostLabelsMode(mode string) error {
+	switch mode {
+	case "":
+		hostLabelsMode = "info"
+	case "info", "attach", "none":
+		hostLabelsMode = mode
+	default:
+		return fmt.Errorf("invalid host labels mode '%s', must be one of info, attach or none", mode)
+	}
+	return nil
+}
+
+func NewLabelsCollector() (Collector, error) {
+	return labelsCollector{}, nil
+}
+
+func (l labelsCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	if hostLabelsMode != "info" {
+		return nil
+	}
+	labels := parseHostLabels(unparsedStats)
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]string, 0, len(names))
+	for _, name := range names {
+		values = append(values, labels[name])
+	}
+	// the label names differ per host
+	desc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "host_labels"),
+		"Host labels reported by the agent",
+		names, nil,
+	)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, values...)
+	return nil
+}
+
+// parseHostLabels handles the JSON objects of host labels, one per line:
+//
+//	{"cmk/os_family": "linux"}
+//
+// The label names are sanitized to valid Prometheus label names, e.g.
+// 'cmk_os_family', with leading underscores reduced to one.
+func parseHostLabels(unparsedStats *[]string) map[string]string {
+	labels := make(map[string]string)
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		var raw map[string]string
+		if err := json.Unmarshal([]byte(stat), &raw); err != nil {
+			log.Debugf("Skipping host labels '%s': %s", stat, err)
+			continue
+		}
+		for name, value := range raw {
+			name = invalidLabelChars.ReplaceAllString(name, "_")
+			if name == "" || (name[0] >= '0' && name[0] <= '9') {
+				name = "_" + name
+			}
+			// names starting with '__' are reserved for Prometheus' internal use
+			if strings.HasPrefix(name, "__") {
+				name = "_" + strings.TrimLeft(name, "_")
+			}
+			labels[name] = value
+		}
+	}
+	return labels
+}
+
+// labelledMetric adds the host labels to a metric, unless it has a label by
+// the same name already.
+type labelledMetric struct {
+	prometheus.Metric
+	labels map[string]string
+}
+
+func (m labelledMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+	existing := make(map[string]struct{})
+	for _, pair := range out.Label {
+		existing[pair.GetName()] = struct{}{}
+	}
+	for name, value := range m.labels {
+		if _, ok := existing[name]; ok {
+			continue
+		}
+		name, value := name, value
+		out.Label = append(out.Label, &dto.LabelPair{Name: &name, Value: &value})
+	}
+	sort.Slice(out.Label, func(i, j int) bool {
+		return out.Label[i].GetName() < out.Label[j].GetName()
+	})
+	return nil
+}
+
+// attachHostLabels returns a channel adding the host labels to the metrics
+// sent to it before passing them on to ch, and a function to call once all
+// metrics have been sent.
+func attachHostLabels(ch chan<- prometheus.Metric, labels map[string]string) (chan<- prometheus.Metric, func()) {
+	in := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for m := range in {
+			ch <- labelledMetric{Metric: m, labels: labels}
+		}
+		close(done)
+	}()
+	return in, func() {
+		close(in)
+		<-done
+	}
+}
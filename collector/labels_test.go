@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestParseHostLabels(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/labels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+
+	labels := parseHostLabels(structuredStats["labels"])
+	for name, want := range map[string]string{
+		"cmk_os_family":   "linux",
+		"cmk_device_type": "vm",
+		"_2fa":            "enabled",
+		"_internal":       "yes",
+		"_site":           "mysite",
+	} {
+		if got := labels[name]; want != got {
+			t.Errorf("want label %s=%s, got '%s'", name, want, got)
+		}
+	}
+	for name := range labels {
+		if strings.HasPrefix(name, "__") {
+			t.Errorf("want no reserved label names, got %s", name)
+		}
+	}
+}
+
+func TestAttachHostLabels(t *testing.T) {
+	desc := prometheus.NewDesc("check_mk_test", "Test metric", []string{"mountpoint", "cmk_os_family"}, nil)
+	out := make(chan prometheus.Metric, 1)
+
+	ch, finish := attachHostLabels(out, map[string]string{"cmk_os_family": "linux", "cmk_device_type": "vm"})
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, "/", "windows")
+	finish()
+
+	var m dto.Metric
+	if err := (<-out).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"cmk_device_type=vm", "cmk_os_family=windows", "mountpoint=/"}
+	if len(m.Label) != len(want) {
+		t.Fatalf("want labels %v, got %v", want, m.Label)
+	}
+	for i, pair := range m.Label {
+		if got := pair.GetName() + "=" + pair.GetValue(); want[i] != got {
+			t.Errorf("want label %s, got %s", want[i], got)
+		}
+	}
+}
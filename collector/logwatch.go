@@ -0,0 +1,206 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/bverschueren/check_mk_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	logwatchLabelNames      = []string{"logfile"}
+	logwatchLinesLabelNames = []string{"logfile", "level"}
+
+	logwatchLevels = map[string]string{
+		"C": "critical",
+		"W": "warning",
+		"O": "ok",
+		"I": "ignore",
+	}
+
+	logwatchRules []logwatchRule
+
+	// the agent only reports lines added since its previous run, so the
+	// counts are kept per target between scrapes
+	logwatchCounts = struct {
+		sync.Mutex
+		m map[string]map[logwatchKey]float64
+	}{m: make(map[string]map[logwatchKey]float64)}
+)
+
+type logwatchRule struct {
+	logfile, pattern *regexp.Regexp
+	level            string
+}
+
+type logwatchKey struct {
+	logfile, level string
+}
+
+type logwatchCollector struct {
+	target         string
+	LinesDesc      *prometheus.Desc
+	MissingDesc    *prometheus.Desc
+	UnreadableDesc *prometheus.Desc
+}
+
+type logwatchStats struct {
+	logfile             string
+	missing, unreadable bool
+	lines               map[string]float64
+}
+
+func init() {
+	registerTargetCollector("logwatch", NewLogwatchCollector)
+}
+
+// SetLogwatchRules configures the rules used to reclassify logwatch lines.
+// The first rule matching both logfile and line determines the line's level.
+func SetLogwatchRules(rules []config.LogwatchRule) error {
+	compiled := []logwatchRule{}
+	for _, r := range rules {
+		if _, ok := logwatchLevels[r.Level]; !ok {
+			return fmt.Errorf("invalid logwatch level '%s', must be one of C, W, O or I", r.Level)
+		}
+		logfile, err := regexp.Compile(r.Logfile)
+		if err != nil {
+			return fmt.Errorf("invalid logwatch logfile pattern '%s': %s", r.Logfile, err)
+		}
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid logwatch pattern '%s': %s", r.Pattern, err)
+		}
+		compiled = append(compiled, logwatchRule{logfile: logfile, pattern: pattern, level: r.Level})
+	}
+	logwatchRules = compiled
+	return nil
+}
+
+func NewLogwatchCollector(target config.Target) (Collector, error) {
+	subsystem := "logwatch"
+
+	LinesDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "lines_total"),
+		"Number of critical and warning lines seen in the logfile",
+		logwatchLinesLabelNames, nil,
+	)
+	MissingDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "logfile_missing"),
+		"Whether the logfile is missing (1) or not (0)",
+		logwatchLabelNames, nil,
+	)
+	UnreadableDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "logfile_unreadable"),
+		"Whether the logfile can't be opened by the agent (1) or not (0)",
+		logwatchLabelNames, nil,
+	)
+	name := target.Name
+	if name == "" {
+		name = target.HostName
+	}
+	return logwatchCollector{
+		target:         name,
+		LinesDesc:      LinesDesc,
+		MissingDesc:    MissingDesc,
+		UnreadableDesc: UnreadableDesc,
+	}, nil
+}
+
+func (l logwatchCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	return l.update(unparsedStats, ch, true)
+}
+
+// Replay reports the logfiles of output handled before, without counting its
+// lines again.
+func (l logwatchCollector) Replay(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	return l.update(unparsedStats, ch, false)
+}
+
+func (l logwatchCollector) update(unparsedStats *[]string, ch chan<- prometheus.Metric, count bool) error {
+	stats := l.parseStats(unparsedStats)
+
+	logwatchCounts.Lock()
+	counts, ok := logwatchCounts.m[l.target]
+	if !ok {
+		counts = make(map[logwatchKey]float64)
+		logwatchCounts.m[l.target] = counts
+	}
+	for _, s := range stats {
+		ch <- prometheus.MustNewConstMetric(
+			l.MissingDesc, prometheus.GaugeValue, boolToFloat(s.missing), s.logfile,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			l.UnreadableDesc, prometheus.GaugeValue, boolToFloat(s.unreadable), s.logfile,
+		)
+		for _, level := range []string{"critical", "warning"} {
+			key := logwatchKey{logfile: s.logfile, level: level}
+			if count {
+				counts[key] += s.lines[level]
+			} else if _, ok := counts[key]; !ok {
+				counts[key] = 0
+			}
+		}
+	}
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(
+			l.LinesDesc, prometheus.CounterValue, count, key.logfile, key.level,
+		)
+	}
+	logwatchCounts.Unlock()
+	return nil
+}
+
+// parseStats handles the new lines per logfile, prefixed by their level and
+// with '.' for context lines:
+//
+//	[[[/var/log/messages]]]
+//	C Aug 12 11:41:45 localhost kernel: I/O error, dev sda, sector 2048
+//	. Aug 12 11:41:45 localhost kernel: Buffer I/O error on dev sda1
+//	[[[/var/log/secure:missing]]]
+func (l logwatchCollector) parseStats(unparsedStats *[]string) []logwatchStats {
+
+	stats := []logwatchStats{}
+	index := make(map[string]int)
+	var cur *logwatchStats
+
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		if strings.HasPrefix(stat, "[[[") && strings.HasSuffix(stat, "]]]") {
+			logfile := strings.TrimSuffix(strings.TrimPrefix(stat, "[[["), "]]]")
+			missing, unreadable := false, false
+			if strings.HasSuffix(logfile, ":missing") {
+				logfile, missing = strings.TrimSuffix(logfile, ":missing"), true
+			} else if strings.HasSuffix(logfile, ":cannotopen") {
+				logfile, unreadable = strings.TrimSuffix(logfile, ":cannotopen"), true
+			}
+			if _, ok := index[logfile]; !ok {
+				index[logfile] = len(stats)
+				stats = append(stats, logwatchStats{logfile: logfile, lines: make(map[string]float64)})
+			}
+			cur = &stats[index[logfile]]
+			cur.missing, cur.unreadable = missing, unreadable
+			continue
+		}
+		if cur == nil || len(stat) == 0 {
+			continue
+		}
+		level, ok := logwatchLevels[stat[:1]]
+		if !ok {
+			// context lines
+			continue
+		}
+		text := strings.TrimSpace(stat[1:])
+		for _, r := range logwatchRules {
+			if r.logfile.MatchString(cur.logfile) && r.pattern.MatchString(text) {
+				log.Debugf("Reclassifying '%s' in '%s' as %s", text, cur.logfile, r.level)
+				level = logwatchLevels[r.level]
+				break
+			}
+		}
+		cur.lines[level]++
+	}
+	return stats
+}
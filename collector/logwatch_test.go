@@ -0,0 +1,93 @@
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/bverschueren/check_mk_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestLogwatchParseStats(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/logwatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+
+	err = SetLogwatchRules([]config.LogwatchRule{
+		{Logfile: "^/var/log/messages$", Pattern: "TIME_ERROR", Level: "I"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer SetLogwatchRules(nil)
+
+	stats := logwatchCollector{}.parseStats(structuredStats["logwatch"])
+	if want, got := 3, len(stats); want != got {
+		t.Fatalf("want %d logfiles, got %d", want, got)
+	}
+	if want, got := 2.0, stats[0].lines["critical"]; want != got {
+		t.Errorf("want %f critical lines, got %f", want, got)
+	}
+	if want, got := 1.0, stats[0].lines["warning"]; want != got {
+		t.Errorf("want %f warning lines after reclassification, got %f", want, got)
+	}
+	if !stats[1].missing || !stats[2].unreadable {
+		t.Errorf("want missing and unreadable logfiles to be flagged, got %+v", stats[1:])
+	}
+}
+
+func TestLogwatchCountsPersist(t *testing.T) {
+	lines := []string{"[[[/var/log/messages]]]", "C kernel: I/O error"}
+	c, err := NewLogwatchCollector(config.Target{Name: "logwatch-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		ch := make(chan prometheus.Metric, 10)
+		c.Update(&lines, ch)
+		close(ch)
+	}
+
+	logwatchCounts.Lock()
+	defer logwatchCounts.Unlock()
+	key := logwatchKey{logfile: "/var/log/messages", level: "critical"}
+	if want, got := 2.0, logwatchCounts.m["logwatch-test"][key]; want != got {
+		t.Errorf("want %f critical lines over two scrapes, got %f", want, got)
+	}
+}
+
+func TestLogwatchReplay(t *testing.T) {
+	lines := []string{"[[[/var/log/messages]]]", "C kernel: I/O error"}
+	c, err := NewLogwatchCollector(config.Target{Name: "logwatch-replay"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resetLogwatchCounts("logwatch-replay")
+
+	ch := make(chan prometheus.Metric, 10)
+	c.(DeltaCollector).Replay(&lines, ch)
+	close(ch)
+	if want, got := 2, len(ch); want > got {
+		t.Errorf("want at least %d metrics for replayed lines, got %d", want, got)
+	}
+	if want, got := 0.0, logwatchCount("logwatch-replay", "/var/log/messages", "critical"); want != got {
+		t.Errorf("want replayed lines not to be counted, got %f", got)
+	}
+}
+
+// logwatchCount returns the lines of the level counted for the logfile.
+func logwatchCount(target, logfile, level string) float64 {
+	logwatchCounts.Lock()
+	defer logwatchCounts.Unlock()
+	return logwatchCounts.m[target][logwatchKey{logfile: logfile, level: level}]
+}
+
+func resetLogwatchCounts(target string) {
+	logwatchCounts.Lock()
+	delete(logwatchCounts.m, target)
+	logwatchCounts.Unlock()
+}
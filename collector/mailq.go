@@ -0,0 +1,189 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	mailqLabelNames = []string{"instance", "queue"}
+
+	// the last line of sendmail compatible 'mailq' output
+	mailqSummary = regexp.MustCompile(`^-- (\d+) Kbytes in (\d+) Requests?\.$`)
+)
+
+// mailqCollector exports the postfix and qmail queue sections as a single
+// check_mk_mailq_* metric family.
+type mailqCollector struct {
+	source     string
+	LengthDesc *prometheus.Desc
+	SizeDesc   *prometheus.Desc
+}
+
+type mailqLabels struct {
+	instance, queue string
+}
+
+type mailqStats struct {
+	length, size float64
+	hasSize      bool
+	labels       mailqLabels
+}
+
+func init() {
+	registerCollector("postfix_mailq", NewPostfixMailqCollector)
+	registerCollector("qmail_stats", NewQmailStatsCollector)
+}
+
+func NewPostfixMailqCollector() (Collector, error) {
+	return newMailqCollector("postfix_mailq"), nil
+}
+
+func NewQmailStatsCollector() (Collector, error) {
+	return newMailqCollector("qmail_stats"), nil
+}
+
+func newMailqCollector(source string) mailqCollector {
+	subsystem := "mailq"
+
+	LengthDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "length"),
+		"Number of mails in the queue",
+		mailqLabelNames, nil,
+	)
+	SizeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "size_bytes"),
+		"Size of the mails in the queue",
+		mailqLabelNames, nil,
+	)
+	return mailqCollector{
+		source:     source,
+		LengthDesc: LengthDesc,
+		SizeDesc:   SizeDesc,
+	}
+}
+
+func (m mailqCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	var stats []mailqStats
+	if m.source == "qmail_stats" {
+		stats = parseQmailStats(unparsedStats)
+	} else {
+		stats = parsePostfixMailqStats(unparsedStats)
+	}
+
+	for _, s := range stats {
+		ch <- prometheus.MustNewConstMetric(
+			m.LengthDesc, prometheus.GaugeValue,
+			s.length, s.labels.instance, s.labels.queue,
+		)
+		if s.hasSize {
+			ch <- prometheus.MustNewConstMetric(
+				m.SizeDesc, prometheus.GaugeValue,
+				s.size, s.labels.instance, s.labels.queue,
+			)
+		}
+	}
+	return nil
+}
+
+// parsePostfixMailqStats handles the queue summaries per postfix instance,
+// with the queue size in KB:
+//
+//	[[[/etc/postfix-out]]]
+//	QUEUE_deferred 60 1
+//	QUEUE_active 4 0
+//
+// as well as the tail of the legacy 'mailq' output, which only knows about
+// the total of all queues:
+//
+//	-- 12 Kbytes in 3 Requests.
+func parsePostfixMailqStats(unparsedStats *[]string) []mailqStats {
+
+	stats := []mailqStats{}
+	seen := make(map[mailqLabels]struct{})
+	instance := "default"
+	add := func(s mailqStats) {
+		if _, ok := seen[s.labels]; ok {
+			return
+		}
+		seen[s.labels] = struct{}{}
+		stats = append(stats, s)
+	}
+
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		line := strings.TrimSpace(stat)
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "[[[") && strings.HasSuffix(line, "]]]"):
+			instance = strings.TrimSuffix(strings.TrimPrefix(line, "[[["), "]]]")
+			if instance == "" {
+				instance = "default"
+			}
+		case len(fields) == 3 && strings.HasPrefix(fields[0], "QUEUE_"):
+			size, _ := strconv.ParseFloat(fields[1], 64)
+			length, _ := strconv.ParseFloat(fields[2], 64)
+			add(mailqStats{
+				labels:  mailqLabels{instance: instance, queue: strings.TrimPrefix(fields[0], "QUEUE_")},
+				length:  length,
+				size:    size * 1024,
+				hasSize: true,
+			})
+		case mailqSummary.MatchString(line):
+			match := mailqSummary.FindStringSubmatch(line)
+			size, _ := strconv.ParseFloat(match[1], 64)
+			length, _ := strconv.ParseFloat(match[2], 64)
+			add(mailqStats{
+				labels:  mailqLabels{instance: instance, queue: "all"},
+				length:  length,
+				size:    size * 1024,
+				hasSize: true,
+			})
+		case strings.HasSuffix(line, "Mail queue is empty"):
+			add(mailqStats{
+				labels:  mailqLabels{instance: instance, queue: "all"},
+				hasSize: true,
+			})
+		default:
+			log.Debugf("Skipping '%s'", stat)
+		}
+	}
+	return stats
+}
+
+// parseQmailStats handles 'qmail-qstat' output:
+//
+//	messages in queue: 5
+//	messages in queue but not yet preprocessed: 0
+func parseQmailStats(unparsedStats *[]string) []mailqStats {
+
+	stats := []mailqStats{}
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		parts := strings.SplitN(stat, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		length, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			continue
+		}
+		queue := ""
+		switch strings.TrimSpace(parts[0]) {
+		case "messages in queue":
+			queue = "all"
+		case "messages in queue but not yet preprocessed":
+			queue = "unprocessed"
+		default:
+			continue
+		}
+		stats = append(stats, mailqStats{
+			labels: mailqLabels{instance: "default", queue: queue},
+			length: length,
+		})
+	}
+	return stats
+}
@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestPostfixMailqParseStats(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/mailq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+
+	stats := parsePostfixMailqStats(structuredStats["postfix_mailq"])
+	if want, got := 6, len(stats); want != got {
+		t.Fatalf("want %d queues, got %d", want, got)
+	}
+	for i, want := range []mailqStats{
+		{labels: mailqLabels{"default", "deferred"}, length: 1, size: 60 * 1024, hasSize: true},
+		{labels: mailqLabels{"default", "active"}, length: 0, size: 4 * 1024, hasSize: true},
+		{labels: mailqLabels{"/etc/postfix-out", "deferred"}, length: 0, size: 0, hasSize: true},
+		{labels: mailqLabels{"/etc/postfix-out", "active"}, length: 3, size: 12 * 1024, hasSize: true},
+		{labels: mailqLabels{"/etc/postfix-legacy", "all"}, length: 3, size: 12 * 1024, hasSize: true},
+		{labels: mailqLabels{"/etc/postfix-empty", "all"}, length: 0, size: 0, hasSize: true},
+	} {
+		if got := stats[i]; want != got {
+			t.Errorf("want %+v, got %+v", want, got)
+		}
+	}
+}
+
+func TestQmailParseStats(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/mailq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+
+	stats := parseQmailStats(structuredStats["qmail_stats"])
+	if want, got := 2, len(stats); want != got {
+		t.Fatalf("want %d queues, got %d", want, got)
+	}
+	if want, got := (mailqStats{labels: mailqLabels{"default", "all"}, length: 5}), stats[0]; want != got {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+	if want, got := (mailqStats{labels: mailqLabels{"default", "unprocessed"}, length: 2}), stats[1]; want != got {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
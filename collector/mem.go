@@ -0,0 +1,226 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	memLabelNames = []string{"type"}
+
+	// meminfo style keys, as '<Type>Total' and '<Type>Free'
+	memTypes = map[string]string{
+		"Mem":  "mem",
+		"Swap": "swap",
+	}
+
+	// 'swap -s' on AIX, in 4KB blocks
+	aixSwap = regexp.MustCompile(`allocated = (\d+) blocks used = (\d+) blocks free = (\d+) blocks`)
+)
+
+const aixPageSize = 4096
+
+// memCollector exports the memory sections of the different agents as a
+// single check_mk_mem_* metric family.
+type memCollector struct {
+	section   string
+	TotalDesc *prometheus.Desc
+	FreeDesc  *prometheus.Desc
+}
+
+type memStats struct {
+	memType     string
+	total, free float64
+	hasTotal    bool
+	hasFree     bool
+}
+
+func init() {
+	registerOSCollector("freebsd", "mem", NewMemCollector)
+	registerOSCollector("freebsd", "statgrab_mem", NewStatgrabMemCollector)
+	registerOSCollector("solaris", "statgrab_mem", NewStatgrabMemCollector)
+	registerOSCollector("aix", "aix_memory", NewAixMemoryCollector)
+}
+
+// NewMemCollector handles the /proc/meminfo style mem section of the FreeBSD
+// agent.
+func NewMemCollector() (Collector, error) {
+	return newMemCollector("mem"), nil
+}
+
+// NewStatgrabMemCollector handles the statgrab output of the Solaris and
+// FreeBSD agents.
+func NewStatgrabMemCollector() (Collector, error) {
+	return newMemCollector("statgrab_mem"), nil
+}
+
+// NewAixMemoryCollector handles the 'vmstat -v' and 'swap -s' output of the
+// AIX agent.
+func NewAixMemoryCollector() (Collector, error) {
+	return newMemCollector("aix_memory"), nil
+}
+
+func newMemCollector(section string) memCollector {
+	subsystem := "mem"
+
+	TotalDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "total_bytes"),
+		"Total memory by type",
+		memLabelNames, nil,
+	)
+	FreeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "free_bytes"),
+		"Free memory by type",
+		memLabelNames, nil,
+	)
+	return memCollector{
+		section:   section,
+		TotalDesc: TotalDesc,
+		FreeDesc:  FreeDesc,
+	}
+}
+
+func (m memCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	var stats []memStats
+	switch m.section {
+	case "statgrab_mem":
+		stats = parseStatgrabMemStats(unparsedStats)
+	case "aix_memory":
+		stats = parseAixMemoryStats(unparsedStats)
+	default:
+		stats = parseMeminfoStats(unparsedStats)
+	}
+
+	for _, s := range stats {
+		if s.hasTotal {
+			ch <- prometheus.MustNewConstMetric(
+				m.TotalDesc, prometheus.GaugeValue, s.total, s.memType,
+			)
+		}
+		if s.hasFree {
+			ch <- prometheus.MustNewConstMetric(
+				m.FreeDesc, prometheus.GaugeValue, s.free, s.memType,
+			)
+		}
+	}
+	return nil
+}
+
+// memStatsFor returns the stats of the memory type, adding them if needed.
+func memStatsFor(stats *[]memStats, memType string) *memStats {
+	for i := range *stats {
+		if (*stats)[i].memType == memType {
+			return &(*stats)[i]
+		}
+	}
+	*stats = append(*stats, memStats{memType: memType})
+	return &(*stats)[len(*stats)-1]
+}
+
+// parseMeminfoStats handles sizes in kB, e.g.
+//
+//	MemTotal:        8291456 kB
+//	SwapFree:        5461504 kB
+func parseMeminfoStats(unparsedStats *[]string) []memStats {
+	stats := []memStats{}
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		parts := strings.SplitN(stat, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields := strings.Fields(parts[1])
+		if len(fields) != 2 || fields[1] != "kB" {
+			continue
+		}
+		kbytes, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		for prefix, memType := range memTypes {
+			switch key {
+			case prefix + "Total":
+				s := memStatsFor(&stats, memType)
+				s.total, s.hasTotal = kbytes*1024, true
+			case prefix + "Free":
+				s := memStatsFor(&stats, memType)
+				s.free, s.hasFree = kbytes*1024, true
+			}
+		}
+	}
+	return stats
+}
+
+// parseStatgrabMemStats handles sizes in bytes, e.g.
+//
+//	mem.total 4294967296
+//	swap.free 4294967296
+func parseStatgrabMemStats(unparsedStats *[]string) []memStats {
+	stats := []memStats{}
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		fields := strings.Fields(stat)
+		if len(fields) != 2 {
+			continue
+		}
+		parts := strings.SplitN(fields[0], ".", 2)
+		if len(parts) != 2 || (parts[0] != "mem" && parts[0] != "swap") {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		switch parts[1] {
+		case "total":
+			s := memStatsFor(&stats, parts[0])
+			s.total, s.hasTotal = value, true
+		case "free":
+			s := memStatsFor(&stats, parts[0])
+			s.free, s.hasFree = value, true
+		}
+	}
+	return stats
+}
+
+// parseAixMemoryStats handles page counts of 'vmstat -v' and the swap usage
+// in 4KB blocks of 'swap -s':
+//
+//	2097152 memory pages
+//	 119957 free pages
+//	allocated = 2097152 blocks used = 80254 blocks free = 2016898 blocks
+func parseAixMemoryStats(unparsedStats *[]string) []memStats {
+	stats := []memStats{}
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		if match := aixSwap.FindStringSubmatch(stat); match != nil {
+			s := memStatsFor(&stats, "swap")
+			total, _ := strconv.ParseFloat(match[1], 64)
+			free, _ := strconv.ParseFloat(match[3], 64)
+			s.total, s.free = total*aixPageSize, free*aixPageSize
+			s.hasTotal, s.hasFree = true, true
+			continue
+		}
+		fields := strings.Fields(stat)
+		if len(fields) != 3 || fields[2] != "pages" {
+			continue
+		}
+		pages, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		switch fields[1] {
+		case "memory":
+			s := memStatsFor(&stats, "mem")
+			s.total, s.hasTotal = pages*aixPageSize, true
+		case "free":
+			s := memStatsFor(&stats, "mem")
+			s.free, s.hasFree = pages*aixPageSize, true
+		}
+	}
+	return stats
+}
@@ -0,0 +1,167 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+var (
+	mysqlStatusLabelNames   = []string{"instance_name", "variable"}
+	mysqlCapacityLabelNames = []string{"instance_name", "schema"}
+	mysqlSlaveLabelNames    = []string{"instance_name"}
+)
+
+// mysqlCollector handles the sections of the mk_mysql plugin, which
+// separates the output per instance by '[[instance]]' lines.
+type mysqlCollector struct {
+	section                 string
+	StatusDesc              *prometheus.Desc
+	SchemaSizeDesc          *prometheus.Desc
+	SchemaFreeDesc          *prometheus.Desc
+	SlaveIORunningDesc      *prometheus.Desc
+	SlaveSQLRunningDesc     *prometheus.Desc
+	SecondsBehindMasterDesc *prometheus.Desc
+}
+
+func init() {
+	registerCollector("mysql_status", NewMysqlStatusCollector)
+	registerCollector("mysql_capacity", NewMysqlCapacityCollector)
+	registerCollector("mysql_slave", NewMysqlSlaveCollector)
+}
+
+func NewMysqlStatusCollector() (Collector, error) {
+	return newMysqlCollector("mysql_status"), nil
+}
+
+func NewMysqlCapacityCollector() (Collector, error) {
+	return newMysqlCollector("mysql_capacity"), nil
+}
+
+func NewMysqlSlaveCollector() (Collector, error) {
+	return newMysqlCollector("mysql_slave"), nil
+}
+
+func newMysqlCollector(section string) mysqlCollector {
+	subsystem := "mysql"
+
+	return mysqlCollector{
+		section: section,
+		StatusDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "global_status"),
+			"Numeric MySQL global status variables",
+			mysqlStatusLabelNames, nil,
+		),
+		SchemaSizeDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "schema_size_bytes"),
+			"Size of the data and indexes of the schema",
+			mysqlCapacityLabelNames, nil,
+		),
+		SchemaFreeDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "schema_free_bytes"),
+			"Allocated but unused space of the schema",
+			mysqlCapacityLabelNames, nil,
+		),
+		SlaveIORunningDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "slave_io_running"),
+			"Whether the slave I/O thread is running (1) or not (0)",
+			mysqlSlaveLabelNames, nil,
+		),
+		SlaveSQLRunningDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "slave_sql_running"),
+			"Whether the slave SQL thread is running (1) or not (0)",
+			mysqlSlaveLabelNames, nil,
+		),
+		SecondsBehindMasterDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "slave_seconds_behind_master"),
+			"Replication lag of the slave",
+			mysqlSlaveLabelNames, nil,
+		),
+	}
+}
+
+func (m mysqlCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	for _, instance := range splitSubsections(unparsedStats, "mysql") {
+		switch m.section {
+		case "mysql_status":
+			m.updateStatus(instance, ch)
+		case "mysql_capacity":
+			m.updateCapacity(instance, ch)
+		case "mysql_slave":
+			m.updateSlave(instance, ch)
+		}
+	}
+	return nil
+}
+
+// updateStatus handles 'SHOW GLOBAL STATUS' output, e.g. 'Threads_connected 3'.
+func (m mysqlCollector) updateStatus(instance subsection, ch chan<- prometheus.Metric) {
+	seen := make(map[string]struct{})
+	for _, stat := range instance.lines {
+		log.Tracef("[raw-structured] %s", stat)
+		fields := strings.Fields(stat)
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		if _, ok := seen[fields[0]]; ok {
+			continue
+		}
+		seen[fields[0]] = struct{}{}
+		ch <- prometheus.MustNewConstMetric(
+			m.StatusDesc, prometheus.UntypedValue, value, instance.name, fields[0],
+		)
+	}
+}
+
+// updateCapacity handles the size per schema as '<schema> <size> <free>'.
+func (m mysqlCollector) updateCapacity(instance subsection, ch chan<- prometheus.Metric) {
+	for _, stat := range instance.lines {
+		log.Tracef("[raw-structured] %s", stat)
+		fields := strings.Fields(stat)
+		if len(fields) != 3 {
+			continue
+		}
+		size, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			// header line or schema without tables
+			continue
+		}
+		free, _ := strconv.ParseFloat(fields[2], 64)
+		ch <- prometheus.MustNewConstMetric(
+			m.SchemaSizeDesc, prometheus.GaugeValue, size, instance.name, fields[0],
+		)
+		ch <- prometheus.MustNewConstMetric(
+			m.SchemaFreeDesc, prometheus.GaugeValue, free, instance.name, fields[0],
+		)
+	}
+}
+
+// updateSlave handles 'SHOW SLAVE STATUS\G' output, e.g.
+//
+//	Slave_IO_Running: Yes
+//	Seconds_Behind_Master: 0
+func (m mysqlCollector) updateSlave(instance subsection, ch chan<- prometheus.Metric) {
+	values := keyValueStats(&instance.lines)
+	if _, ok := values["Slave_IO_Running"]; !ok {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(
+		m.SlaveIORunningDesc, prometheus.GaugeValue,
+		boolToFloat(values["Slave_IO_Running"] == "Yes"), instance.name,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		m.SlaveSQLRunningDesc, prometheus.GaugeValue,
+		boolToFloat(values["Slave_SQL_Running"] == "Yes"), instance.name,
+	)
+	// NULL while replication is stopped
+	if lag, err := strconv.ParseFloat(values["Seconds_Behind_Master"], 64); err == nil {
+		ch <- prometheus.MustNewConstMetric(
+			m.SecondsBehindMasterDesc, prometheus.GaugeValue, lag, instance.name,
+		)
+	}
+}
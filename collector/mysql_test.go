@@ -0,0 +1,30 @@
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestMysqlUpdate(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/mysql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+
+	for section, want := range map[string]int{
+		"mysql_status":   5,
+		"mysql_capacity": 2,
+		// no replication lag while the SQL thread is stopped
+		"mysql_slave": 2,
+	} {
+		metrics, err := collectMetrics(newMysqlCollector(section), structuredStats[section])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(metrics); want != got {
+			t.Errorf("want %d metrics for %s, got %d", want, section, got)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+var (
+	networkMountLabelNames = []string{"mountpoint"}
+)
+
+type networkMountsCollector struct {
+	UpDesc        *prometheus.Desc
+	SizeDesc      *prometheus.Desc
+	FreeDesc      *prometheus.Desc
+	AvailableDesc *prometheus.Desc
+}
+
+type networkMountStats struct {
+	mountPoint, state     string
+	size, free, available float64
+}
+
+func init() {
+	registerCollector("nfsmounts", NewNfsMountsCollector)
+	registerCollector("cifsmounts", NewCifsMountsCollector)
+}
+
+func NewNfsMountsCollector() (Collector, error) {
+	return newNetworkMountsCollector("nfsmounts"), nil
+}
+
+func NewCifsMountsCollector() (Collector, error) {
+	return newNetworkMountsCollector("cifsmounts"), nil
+}
+
+func newNetworkMountsCollector(subsystem string) networkMountsCollector {
+	UpDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "mount_up"),
+		"Whether the mount responded to stat (1) or is hanging or inaccessible (0)",
+		networkMountLabelNames, nil,
+	)
+	SizeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "size_bytes"),
+		"Mount total size in bytes",
+		networkMountLabelNames, nil,
+	)
+	FreeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "free_bytes"),
+		"Mount free size in bytes",
+		networkMountLabelNames, nil,
+	)
+	AvailableDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "available_bytes"),
+		"Mount size available to non-root users in bytes",
+		networkMountLabelNames, nil,
+	)
+	return networkMountsCollector{
+		UpDesc:        UpDesc,
+		SizeDesc:      SizeDesc,
+		FreeDesc:      FreeDesc,
+		AvailableDesc: AvailableDesc,
+	}
+}
+
+func (n networkMountsCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	stats := n.parseStats(unparsedStats)
+
+	for _, s := range stats {
+		if s.state != "ok" {
+			ch <- prometheus.MustNewConstMetric(
+				n.UpDesc, prometheus.GaugeValue, 0, s.mountPoint,
+			)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			n.UpDesc, prometheus.GaugeValue, 1, s.mountPoint,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			n.SizeDesc, prometheus.GaugeValue, s.size, s.mountPoint,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			n.FreeDesc, prometheus.GaugeValue, s.free, s.mountPoint,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			n.AvailableDesc, prometheus.GaugeValue, s.available, s.mountPoint,
+		)
+	}
+	return nil
+}
+
+// parseStats handles the lines written by the agent's stat call on each mount:
+//
+//	<mountpoint> ok <blocks> <free blocks> <available blocks> <block size>
+//	<mountpoint> hanging 0 0 0 0
+//	<mountpoint> Permission denied
+//
+// Mountpoints may contain spaces, so fields are taken from the end of the line.
+func (n networkMountsCollector) parseStats(unparsedStats *[]string) []networkMountStats {
+
+	stats := []networkMountStats{}
+	seen := make(map[string]struct{})
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		fields := strings.Fields(stat)
+		var s networkMountStats
+		switch {
+		case len(fields) >= 3 && strings.Join(fields[len(fields)-2:], " ") == "Permission denied":
+			s = networkMountStats{
+				mountPoint: strings.Join(fields[:len(fields)-2], " "),
+				state:      "permission_denied",
+			}
+		case len(fields) >= 6:
+			values := fields[len(fields)-4:]
+			blocks, _ := strconv.ParseFloat(values[0], 64)
+			free, _ := strconv.ParseFloat(values[1], 64)
+			available, _ := strconv.ParseFloat(values[2], 64)
+			blockSize, _ := strconv.ParseFloat(values[3], 64)
+			s = networkMountStats{
+				mountPoint: strings.Join(fields[:len(fields)-5], " "),
+				state:      fields[len(fields)-5],
+				size:       blocks * blockSize,
+				free:       free * blockSize,
+				available:  available * blockSize,
+			}
+		default:
+			log.Debugf("Skipping '%s'", stat)
+			continue
+		}
+		if _, ok := seen[s.mountPoint]; ok {
+			continue
+		}
+		seen[s.mountPoint] = struct{}{}
+		stats = append(stats, s)
+	}
+	return stats
+}
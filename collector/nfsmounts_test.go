@@ -0,0 +1,38 @@
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestNetworkMountsParseStats(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/nfsmounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+
+	stats := newNetworkMountsCollector("nfsmounts").parseStats(structuredStats["nfsmounts"])
+	if want, got := 3, len(stats); want != got {
+		t.Fatalf("want %d nfs mounts, got %d", want, got)
+	}
+	byMountPoint := make(map[string]networkMountStats)
+	for _, s := range stats {
+		byMountPoint[s.mountPoint] = s
+	}
+	if want, got := float64(26214400*4096), byMountPoint["/mnt/backup"].size; want != got {
+		t.Errorf("want size %f for /mnt/backup, got %f", want, got)
+	}
+	if want, got := "hanging", byMountPoint["/mnt/stale share"].state; want != got {
+		t.Errorf("want state %s for '/mnt/stale share', got %s", want, got)
+	}
+	if want, got := "permission_denied", byMountPoint["/mnt/restricted"].state; want != got {
+		t.Errorf("want state %s for /mnt/restricted, got %s", want, got)
+	}
+
+	stats = newNetworkMountsCollector("cifsmounts").parseStats(structuredStats["cifsmounts"])
+	if want, got := 1, len(stats); want != got {
+		t.Errorf("want %d cifs mounts, got %d", want, got)
+	}
+}
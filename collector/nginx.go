@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+var (
+	nginxLabelNames      = []string{"address", "port"}
+	nginxStateLabelNames = []string{"address", "port", "state"}
+)
+
+type nginxCollector struct {
+	ActiveDesc      *prometheus.Desc
+	AcceptedDesc    *prometheus.Desc
+	HandledDesc     *prometheus.Desc
+	RequestsDesc    *prometheus.Desc
+	ConnectionsDesc *prometheus.Desc
+}
+
+type nginxServer struct {
+	address, port string
+}
+
+type nginxStats struct {
+	server                      nginxServer
+	active                      float64
+	accepted, handled, requests float64
+	hasCounters                 bool
+	states                      map[string]float64
+}
+
+func init() {
+	registerCollector("nginx_status", NewNginxCollector)
+}
+
+func NewNginxCollector() (Collector, error) {
+	subsystem := "nginx"
+
+	ActiveDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "connections_active"),
+		"Number of active client connections",
+		nginxLabelNames, nil,
+	)
+	AcceptedDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "connections_accepted_total"),
+		"Number of accepted client connections",
+		nginxLabelNames, nil,
+	)
+	HandledDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "connections_handled_total"),
+		"Number of handled client connections",
+		nginxLabelNames, nil,
+	)
+	RequestsDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "requests_total"),
+		"Number of client requests",
+		nginxLabelNames, nil,
+	)
+	ConnectionsDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "connections"),
+		"Number of client connections by state",
+		nginxStateLabelNames, nil,
+	)
+	return nginxCollector{
+		ActiveDesc:      ActiveDesc,
+		AcceptedDesc:    AcceptedDesc,
+		HandledDesc:     HandledDesc,
+		RequestsDesc:    RequestsDesc,
+		ConnectionsDesc: ConnectionsDesc,
+	}, nil
+}
+
+func (n nginxCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	stats := n.parseStats(unparsedStats)
+
+	for _, s := range stats {
+		labels := []string{s.server.address, s.server.port}
+		ch <- prometheus.MustNewConstMetric(
+			n.ActiveDesc, prometheus.GaugeValue, s.active, labels...,
+		)
+		if s.hasCounters {
+			ch <- prometheus.MustNewConstMetric(
+				n.AcceptedDesc, prometheus.CounterValue, s.accepted, labels...,
+			)
+			ch <- prometheus.MustNewConstMetric(
+				n.HandledDesc, prometheus.CounterValue, s.handled, labels...,
+			)
+			ch <- prometheus.MustNewConstMetric(
+				n.RequestsDesc, prometheus.CounterValue, s.requests, labels...,
+			)
+		}
+		for state, value := range s.states {
+			ch <- prometheus.MustNewConstMetric(
+				n.ConnectionsDesc, prometheus.GaugeValue, value, append(labels, state)...,
+			)
+		}
+	}
+	return nil
+}
+
+// parseStats handles the stub_status output per server, prefixed by its
+// address and port:
+//
+//	127.0.0.1 80 Active connections: 3
+//	127.0.0.1 80 server accepts handled requests
+//	127.0.0.1 80  1021 1021 2467
+//	127.0.0.1 80 Reading: 0 Writing: 1 Waiting: 2
+func (n nginxCollector) parseStats(unparsedStats *[]string) []nginxStats {
+
+	stats := []nginxStats{}
+	index := make(map[nginxServer]int)
+
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		fields := strings.Fields(stat)
+		if len(fields) < 3 {
+			continue
+		}
+		server := nginxServer{address: fields[0], port: fields[1]}
+		if _, ok := index[server]; !ok {
+			index[server] = len(stats)
+			stats = append(stats, nginxStats{server: server, states: make(map[string]float64)})
+		}
+		s := &stats[index[server]]
+		fields = fields[2:]
+
+		switch {
+		case fields[0] == "Active" && len(fields) == 3:
+			s.active, _ = strconv.ParseFloat(fields[2], 64)
+		case fields[0] == "Reading:" && len(fields) == 6:
+			for i := 0; i < len(fields); i += 2 {
+				state := strings.ToLower(strings.TrimSuffix(fields[i], ":"))
+				s.states[state], _ = strconv.ParseFloat(fields[i+1], 64)
+			}
+		case len(fields) == 3:
+			values := make([]float64, 3)
+			var err error
+			for i, field := range fields {
+				if values[i], err = strconv.ParseFloat(field, 64); err != nil {
+					break
+				}
+			}
+			if err != nil {
+				// the 'server accepts handled requests' header
+				continue
+			}
+			s.accepted, s.handled, s.requests = values[0], values[1], values[2]
+			s.hasCounters = true
+		}
+	}
+	return stats
+}
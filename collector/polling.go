@@ -0,0 +1,147 @@
+package collector
+
+import (
+	"github.com/bverschueren/check_mk_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	cacheAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "age_seconds"),
+		"Age of the results of the target's last poll",
+		nil, nil,
+	)
+	pollSuccessDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "poll_success"),
+		"Whether the target's last poll fetched the agent output (1) or not (0)",
+		nil, nil,
+	)
+
+	// the metrics and inventory of the last poll per target
+	polledResults      = make(map[string]polledResult)
+	polledResultsMutex sync.RWMutex
+)
+
+const (
+	// the number of concurrent polls, unless configured
+	pollConcurrency = 4
+)
+
+type polledResult struct {
+	metrics   []prometheus.Metric
+	inventory *Inventory
+	polled    time.Time
+	success   bool
+}
+
+// cachedCollector serves the results of a target's last poll.
+type cachedCollector struct {
+	polledResult
+}
+
+// StartPolling polls the targets with a poll interval in the background, at
+// most concurrency at once. Each poll is delayed randomly up to jitter, to
+// spread the polls of targets with the same interval.
+func StartPolling(targets map[string]config.Target, concurrency int, jitter time.Duration) {
+	if concurrency <= 0 {
+		concurrency = pollConcurrency
+	}
+	slots := make(chan struct{}, concurrency)
+	for _, target := range targets {
+		if target.PollInterval <= 0 {
+			continue
+		}
+		log.Infof("Polling '%s' every %s", target.Name, target.PollInterval)
+		go func(target config.Target) {
+			for {
+				delay := time.Duration(0)
+				if jitter > 0 {
+					delay = time.Duration(rand.Int63n(int64(jitter)))
+				}
+				time.Sleep(delay)
+				start := time.Now()
+				pollTarget(target, slots)
+				if wait := target.PollInterval - time.Since(start) - delay; wait > 0 {
+					time.Sleep(wait)
+				}
+			}
+		}(target)
+	}
+}
+
+// pollTarget collects the target's metrics and inventory, keeping them for its
+// scrapes.
+// Should the agent output not be fetched, the previous results are kept.
+func pollTarget(target config.Target, slots chan struct{}) {
+	slots <- struct{}{}
+	defer func() { <-slots }()
+
+	log.Debugf("Polling '%s'", target.Name)
+	mc, err := NewMKCheckCollector(target)
+	if err != nil {
+		log.Errorf("Unable to poll '%s': %s", target.Name, err)
+		return
+	}
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	metrics := []prometheus.Metric{}
+	go func() {
+		for m := range ch {
+			metrics = append(metrics, m)
+		}
+		close(done)
+	}()
+	structuredStats := mc.collect(ch)
+	close(ch)
+	<-done
+	success := structuredStats != nil
+
+	polledResultsMutex.Lock()
+	defer polledResultsMutex.Unlock()
+	// a failed poll doesn't replace the results of the last successful one
+	if previous, ok := polledResults[target.Name]; ok && !success {
+		log.Warnf("Polling '%s' failed, keeping the results of %s", target.Name, previous.polled.Format(time.RFC3339))
+		previous.success = false
+		polledResults[target.Name] = previous
+		return
+	}
+	result := polledResult{metrics: metrics, polled: time.Now(), success: success}
+	if success {
+		result.inventory = parseInventory(structuredStats)
+	}
+	polledResults[target.Name] = result
+}
+
+// NewCachedCollector returns a collector for the results of the target's last
+// poll, if it was polled yet.
+func NewCachedCollector(target config.Target) (prometheus.Collector, bool) {
+	polledResultsMutex.RLock()
+	defer polledResultsMutex.RUnlock()
+	result, ok := polledResults[target.Name]
+	return cachedCollector{result}, ok
+}
+
+// PolledInventory returns the inventory of the target's last successful poll,
+// if it was polled yet.
+func PolledInventory(target config.Target) (*Inventory, bool) {
+	polledResultsMutex.RLock()
+	defer polledResultsMutex.RUnlock()
+	result, ok := polledResults[target.Name]
+	return result.inventory, ok && result.inventory != nil
+}
+
+func (c cachedCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(cacheAgeDesc, prometheus.GaugeValue, time.Since(c.polled).Seconds())
+	ch <- prometheus.MustNewConstMetric(pollSuccessDesc, prometheus.GaugeValue, boolToFloat(c.success))
+	for _, m := range c.metrics {
+		ch <- m
+	}
+}
+
+func (c cachedCollector) Describe(ch chan<- *prometheus.Desc) {
+	// the metrics depend on the agent output, as for CheckMKCollector
+}
@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"github.com/bverschueren/check_mk_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"testing"
+	"time"
+)
+
+func TestPolling(t *testing.T) {
+	target := config.Target{
+		Name:         "polled",
+		Transport:    "exec",
+		Command:      "cat ../testdata/check_mk ../testdata/df",
+		PollInterval: time.Hour,
+	}
+	if _, ok := NewCachedCollector(target); ok {
+		t.Fatal("want no results before the first poll")
+	}
+	StartPolling(map[string]config.Target{"polled": target}, 1, 0)
+
+	var c prometheus.Collector
+	var ok bool
+	for deadline := time.Now().Add(5 * time.Second); !ok && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		c, ok = NewCachedCollector(target)
+	}
+	if !ok {
+		t.Fatal("want results after the first poll")
+	}
+
+	ch := make(chan prometheus.Metric, 100)
+	c.Collect(ch)
+	close(ch)
+	df, _ := NewDfCollector()
+	var age, size bool
+	for m := range ch {
+		age = age || m.Desc() == cacheAgeDesc
+		size = size || m.Desc().String() == df.(dfCollector).SizeDesc.String()
+	}
+	if !age || !size {
+		t.Errorf("want the cache age (%v) and the polled metrics (%v)", age, size)
+	}
+}
+
+func TestPollTargetFailure(t *testing.T) {
+	target := config.Target{
+		Name:         "flaky",
+		Transport:    "exec",
+		Command:      "cat ../testdata/check_mk ../testdata/df",
+		PollInterval: time.Hour,
+	}
+	defer func() {
+		polledResultsMutex.Lock()
+		delete(polledResults, "flaky")
+		polledResultsMutex.Unlock()
+	}()
+	slots := make(chan struct{}, 1)
+	df, _ := NewDfCollector()
+
+	for _, c := range []struct {
+		command       string
+		success, size bool
+	}{
+		{"/nonexistent/check_mk_agent", false, false},
+		{target.Command, true, true},
+		// the results of the previous poll are kept
+		{"/nonexistent/check_mk_agent", false, true},
+	} {
+		target.Command = c.command
+		pollTarget(target, slots)
+		cached, ok := NewCachedCollector(target)
+		if !ok {
+			t.Fatalf("want results after polling '%s'", c.command)
+		}
+
+		ch := make(chan prometheus.Metric, 100)
+		cached.Collect(ch)
+		close(ch)
+		success, size := false, false
+		for m := range ch {
+			size = size || m.Desc().String() == df.(dfCollector).SizeDesc.String()
+			if m.Desc() == pollSuccessDesc {
+				var metric dto.Metric
+				m.Write(&metric)
+				success = metric.GetGauge().GetValue() == 1
+			}
+		}
+		if c.success != success || c.size != size {
+			t.Errorf("want poll success %v and df metrics %v after polling '%s', got %v and %v", c.success, c.size, c.command, success, size)
+		}
+	}
+}
+
+func TestPolledInventory(t *testing.T) {
+	target := config.Target{
+		Name:         "inventoried",
+		Transport:    "exec",
+		Command:      "cat ../testdata/check_mk ../testdata/inventory",
+		PollInterval: time.Hour,
+	}
+	defer func() {
+		polledResultsMutex.Lock()
+		delete(polledResults, "inventoried")
+		polledResultsMutex.Unlock()
+	}()
+	if _, ok := PolledInventory(target); ok {
+		t.Fatal("want no inventory before the first poll")
+	}
+
+	pollTarget(target, make(chan struct{}, 1))
+	inv, ok := PolledInventory(target)
+	if !ok {
+		t.Fatal("want the inventory of the first poll")
+	}
+	if want, got := "Ubuntu", inv.OS.Name; want != got {
+		t.Errorf("want OS %s, got %s", want, got)
+	}
+
+	// the inventory of the previous poll is kept
+	target.Command = "/nonexistent/check_mk_agent"
+	pollTarget(target, make(chan struct{}, 1))
+	if inv, ok := PolledInventory(target); !ok || inv.OS.Name != "Ubuntu" {
+		t.Errorf("want the inventory of the last successful poll, got %+v", inv)
+	}
+}
@@ -0,0 +1,225 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+var (
+	postgresLabelNames             = []string{"instance_name"}
+	postgresSessionsLabelNames     = []string{"instance_name", "state"}
+	postgresStatDatabaseLabelNames = []string{"instance_name", "datname"}
+	postgresLocksLabelNames        = []string{"instance_name", "datname", "mode", "granted"}
+
+	// columns of pg_stat_database and the type of their metric
+	postgresStatDatabaseColumns = map[string]prometheus.ValueType{
+		"numbackends":   prometheus.GaugeValue,
+		"xact_commit":   prometheus.CounterValue,
+		"xact_rollback": prometheus.CounterValue,
+		"blks_read":     prometheus.CounterValue,
+		"blks_hit":      prometheus.CounterValue,
+		"tup_returned":  prometheus.CounterValue,
+		"tup_fetched":   prometheus.CounterValue,
+		"tup_inserted":  prometheus.CounterValue,
+		"tup_updated":   prometheus.CounterValue,
+		"tup_deleted":   prometheus.CounterValue,
+		"datsize":       prometheus.GaugeValue,
+	}
+)
+
+// postgresCollector handles the sections of the mk_postgres plugin, which
+// separates the output per instance by '[[[instance]]]' lines and the
+// columns of its queries by ';'.
+type postgresCollector struct {
+	section          string
+	InstanceUpDesc   *prometheus.Desc
+	SessionsDesc     *prometheus.Desc
+	StatDatabaseDesc map[string]*prometheus.Desc
+	LocksDesc        *prometheus.Desc
+}
+
+func init() {
+	registerCollector("postgres_instances", NewPostgresInstancesCollector)
+	registerCollector("postgres_sessions", NewPostgresSessionsCollector)
+	registerCollector("postgres_stat_database", NewPostgresStatDatabaseCollector)
+	registerCollector("postgres_locks", NewPostgresLocksCollector)
+}
+
+func NewPostgresInstancesCollector() (Collector, error) {
+	return newPostgresCollector("postgres_instances"), nil
+}
+
+func NewPostgresSessionsCollector() (Collector, error) {
+	return newPostgresCollector("postgres_sessions"), nil
+}
+
+func NewPostgresStatDatabaseCollector() (Collector, error) {
+	return newPostgresCollector("postgres_stat_database"), nil
+}
+
+func NewPostgresLocksCollector() (Collector, error) {
+	return newPostgresCollector("postgres_locks"), nil
+}
+
+func newPostgresCollector(section string) postgresCollector {
+	subsystem := "postgres"
+
+	StatDatabaseDesc := make(map[string]*prometheus.Desc)
+	for column := range postgresStatDatabaseColumns {
+		StatDatabaseDesc[column] = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "stat_database_"+column),
+			"Column "+column+" of pg_stat_database",
+			postgresStatDatabaseLabelNames, nil,
+		)
+	}
+	return postgresCollector{
+		section: section,
+		InstanceUpDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "instance_up"),
+			"Whether a postgres process is running for the instance (1) or not (0)",
+			postgresLabelNames, nil,
+		),
+		SessionsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "sessions"),
+			"Number of idle and active sessions",
+			postgresSessionsLabelNames, nil,
+		),
+		StatDatabaseDesc: StatDatabaseDesc,
+		LocksDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "locks"),
+			"Number of locks by database, mode and whether they are granted",
+			postgresLocksLabelNames, nil,
+		),
+	}
+}
+
+func (p postgresCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	for _, instance := range splitSubsections(unparsedStats, "main") {
+		switch p.section {
+		case "postgres_instances":
+			p.updateInstances(instance, ch)
+		case "postgres_sessions":
+			p.updateSessions(instance, ch)
+		case "postgres_stat_database":
+			p.updateStatDatabase(instance, ch)
+		case "postgres_locks":
+			p.updateLocks(instance, ch)
+		}
+	}
+	return nil
+}
+
+// updateInstances handles the postgres processes of the instance, listed as
+// '<pid> <command line>'.
+func (p postgresCollector) updateInstances(instance subsection, ch chan<- prometheus.Metric) {
+	up := false
+	for _, stat := range instance.lines {
+		log.Tracef("[raw-structured] %s", stat)
+		fields := strings.Fields(stat)
+		if len(fields) < 2 {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[0]); err == nil {
+			up = true
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(
+		p.InstanceUpDesc, prometheus.GaugeValue, boolToFloat(up), instance.name,
+	)
+}
+
+// updateSessions handles the number of sessions by whether they are idle,
+// e.g. 't;5' and 'f;2'.
+func (p postgresCollector) updateSessions(instance subsection, ch chan<- prometheus.Metric) {
+	sessions := map[string]float64{"idle": 0, "active": 0}
+	for _, stat := range instance.lines {
+		log.Tracef("[raw-structured] %s", stat)
+		fields := strings.Split(stat, ";")
+		if len(fields) != 2 {
+			continue
+		}
+		count, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		if fields[0] == "t" {
+			sessions["idle"] += count
+		} else {
+			sessions["active"] += count
+		}
+	}
+	for state, count := range sessions {
+		ch <- prometheus.MustNewConstMetric(
+			p.SessionsDesc, prometheus.GaugeValue, count, instance.name, state,
+		)
+	}
+}
+
+// updateStatDatabase handles pg_stat_database rows, preceded by their
+// column names:
+//
+//	datid;datname;numbackends;xact_commit;...;datsize
+//	16384;app;3;104215;12;...;8029704
+func (p postgresCollector) updateStatDatabase(instance subsection, ch chan<- prometheus.Metric) {
+	rows := postgresRows(instance.lines)
+	for _, row := range rows {
+		if row["datname"] == "" {
+			continue
+		}
+		for column, valueType := range postgresStatDatabaseColumns {
+			value, err := strconv.ParseFloat(row[column], 64)
+			if err != nil {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(
+				p.StatDatabaseDesc[column], valueType, value, instance.name, row["datname"],
+			)
+		}
+	}
+}
+
+// updateLocks handles the locks held or requested per database:
+//
+//	datname;granted;mode
+//	app;t;AccessShareLock
+func (p postgresCollector) updateLocks(instance subsection, ch chan<- prometheus.Metric) {
+	type lockKey struct{ datname, mode, granted string }
+	locks := make(map[lockKey]float64)
+	for _, row := range postgresRows(instance.lines) {
+		granted := "false"
+		if row["granted"] == "t" {
+			granted = "true"
+		}
+		locks[lockKey{row["datname"], row["mode"], granted}]++
+	}
+	for key, count := range locks {
+		ch <- prometheus.MustNewConstMetric(
+			p.LocksDesc, prometheus.GaugeValue, count, instance.name, key.datname, key.mode, key.granted,
+		)
+	}
+}
+
+// postgresRows maps the ';' separated rows to the column names of the first
+// line.
+func postgresRows(lines []string) []map[string]string {
+	rows := []map[string]string{}
+	var columns []string
+	for _, stat := range lines {
+		log.Tracef("[raw-structured] %s", stat)
+		fields := strings.Split(stat, ";")
+		if columns == nil {
+			columns = fields
+			continue
+		}
+		row := make(map[string]string)
+		for i, column := range columns {
+			if i < len(fields) {
+				row[column] = fields[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestSplitSubsections(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+
+	instances := splitSubsections(structuredStats["postgres_instances"], "main")
+	if want, got := 2, len(instances); want != got {
+		t.Fatalf("want %d instances, got %d", want, got)
+	}
+	if want, got := "reporting", instances[1].name; want != got {
+		t.Errorf("want instance %s, got %s", want, got)
+	}
+	if want, got := 0, len(instances[1].lines); want != got {
+		t.Errorf("want %d lines for instance without processes, got %d", want, got)
+	}
+
+	lines := []string{"Uptime 60", "[[replica]]", "Uptime 30"}
+	subsections := splitSubsections(&lines, "mysql")
+	if want, got := "mysql", subsections[0].name; want != got {
+		t.Errorf("want lines before the first header in %s, got %s", want, got)
+	}
+}
+
+func TestPostgresUpdate(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+
+	for section, want := range map[string]int{
+		"postgres_instances":     2,
+		"postgres_sessions":      2,
+		"postgres_stat_database": 2 * len(postgresStatDatabaseColumns),
+		"postgres_locks":         2,
+	} {
+		metrics, err := collectMetrics(newPostgresCollector(section), structuredStats[section])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(metrics); want != got {
+			t.Errorf("want %d metrics for %s, got %d", want, section, got)
+		}
+	}
+}
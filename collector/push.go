@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bverschueren/check_mk_exporter/config"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	// the latest output pushed per target
+	pushedOutputs      = make(map[string]pushedOutput)
+	pushedOutputsMutex sync.RWMutex
+)
+
+type pushedOutput struct {
+	data     []byte
+	received time.Time
+}
+
+// StorePushedOutput keeps the agent output pushed by a target for its next
+// scrapes. It's written to the target's spool directory as well, if any, to
+// be available after a restart.
+func StorePushedOutput(target config.Target, data []byte) error {
+	if target.SpoolDirectory != "" {
+		tmp, err := ioutil.TempFile(target.SpoolDirectory, "."+target.Name)
+		if err != nil {
+			return err
+		}
+		_, err = tmp.Write(data)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), filepath.Join(target.SpoolDirectory, target.Name))
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+			return err
+		}
+	}
+
+	pushedOutputsMutex.Lock()
+	pushedOutputs[target.Name] = pushedOutput{data: data, received: time.Now()}
+	pushedOutputsMutex.Unlock()
+	log.Debugf("Stored %d bytes pushed by '%s'", len(data), target.Name)
+	return nil
+}
+
+// DropPushedOutput forgets the output pushed by the target, if any. Output
+// spooled to disk is kept.
+func DropPushedOutput(name string) {
+	pushedOutputsMutex.Lock()
+	delete(pushedOutputs, name)
+	pushedOutputsMutex.Unlock()
+}
+
+func lookupPushedOutput(name string) (pushedOutput, bool) {
+	pushedOutputsMutex.RLock()
+	defer pushedOutputsMutex.RUnlock()
+	output, ok := pushedOutputs[name]
+	return output, ok
+}
+
+// collectPushRawStats returns the output the target pushed last, falling
+// back to the spool directory after a restart.
+func (mc CheckMKCollector) collectPushRawStats() (*bytes.Buffer, error) {
+	output, ok := lookupPushedOutput(mc.target.Name)
+	if !ok {
+		if mc.target.SpoolDirectory != "" {
+			return mc.collectFileRawStats()
+		}
+		err := fmt.Errorf("no output pushed by '%s' yet", mc.target.Name)
+		log.Infof("Unable to collect stats from '%s': %s", mc.target.Name, err)
+		return nil, err
+	}
+	age := time.Since(output.received)
+	if mc.target.MaxAge > 0 && age > mc.target.MaxAge {
+		err := fmt.Errorf("output is outdated, pushed %s ago", age.Truncate(time.Second))
+		log.Warnf("Unable to collect stats from '%s': %s", mc.target.Name, err)
+		return nil, err
+	}
+	log.Trace("Raw check_mk stats: " + string(output.data))
+	return bytes.NewBuffer(output.data), nil
+}
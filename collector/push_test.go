@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"github.com/bverschueren/check_mk_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCollectPushRawStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	target := config.Target{Name: "pushing", Transport: "push", SpoolDirectory: dir}
+	mc := CheckMKCollector{target: target}
+	defer DropPushedOutput("pushing")
+	if _, err := mc.collectRawStats(); err == nil {
+		t.Error("want an error before the target pushed its output")
+	}
+
+	output := []byte("<<<check_mk>>>\nVersion: 2.0.0\n")
+	if err := StorePushedOutput(target, output); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := mc.collectRawStats()
+	if err != nil || raw.String() != string(output) {
+		t.Errorf("want the pushed output, got %q (%v)", raw, err)
+	}
+	spooled, err := ioutil.ReadFile(filepath.Join(dir, "pushing"))
+	if err != nil || string(spooled) != string(output) {
+		t.Errorf("want the pushed output to be spooled, got %q (%v)", spooled, err)
+	}
+
+	// after a restart
+	DropPushedOutput("pushing")
+	raw, err = mc.collectRawStats()
+	if err != nil || raw.String() != string(output) {
+		t.Errorf("want the spooled output, got %q (%v)", raw, err)
+	}
+
+	mc.target.SpoolDirectory = ""
+	mc.target.MaxAge = time.Minute
+	pushedOutputsMutex.Lock()
+	pushedOutputs["pushing"] = pushedOutput{data: output, received: time.Now().Add(-time.Hour)}
+	pushedOutputsMutex.Unlock()
+	if _, err := mc.collectRawStats(); err == nil {
+		t.Error("want an error for outdated output")
+	}
+}
+
+func TestCollectPushLogwatch(t *testing.T) {
+	output, err := ioutil.ReadFile("../testdata/logwatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := config.Target{Name: "pushing-logwatch", Transport: "push"}
+	defer DropPushedOutput("pushing-logwatch")
+	defer resetLogwatchCounts("pushing-logwatch")
+	mc, err := NewMKCheckCollector(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scrape := func() float64 {
+		ch := make(chan prometheus.Metric, 100)
+		mc.Collect(ch)
+		close(ch)
+		return logwatchCount("pushing-logwatch", "/var/log/messages", "critical")
+	}
+
+	for _, want := range []float64{2, 4} {
+		if err := StorePushedOutput(target, output); err != nil {
+			t.Fatal(err)
+		}
+		// each push is counted once, however often it's scraped
+		for i := 0; i < 2; i++ {
+			if got := scrape(); want != got {
+				t.Errorf("want %f critical lines, got %f", want, got)
+			}
+		}
+	}
+}
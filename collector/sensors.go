@@ -0,0 +1,299 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// the source is the section, as sensor names may repeat across them
+	sensorLabelNames          = []string{"source", "sensor", "type", "unit"}
+	sensorStatusLabelNames    = []string{"source", "sensor", "type"}
+	sensorTripPointLabelNames = []string{"source", "sensor", "type", "unit", "trip_point", "trip_point_index"}
+
+	ipmiUnits = map[string]string{
+		"C":   "celsius",
+		"F":   "fahrenheit",
+		"RPM": "rpm",
+		"V":   "volts",
+		"A":   "amperes",
+		"W":   "watts",
+		"%":   "percent",
+	}
+	lmSensorsTypes = map[string][2]string{
+		"temp":  {"temperature", "celsius"},
+		"fan":   {"fan", "rpm"},
+		"in":    {"voltage", "volts"},
+		"curr":  {"current", "amperes"},
+		"power": {"power", "watts"},
+	}
+	lmSensorsSubfeature = regexp.MustCompile(`^([a-z]+)\d+_([a-z_]+)$`)
+)
+
+// sensorCollector exports the hardware sensor sections as a single
+// check_mk_sensor_* metric family.
+type sensorCollector struct {
+	source        string
+	ValueDesc     *prometheus.Desc
+	StatusDesc    *prometheus.Desc
+	TripPointDesc *prometheus.Desc
+}
+
+type sensorTripPoint struct {
+	tripType string
+	value    float64
+}
+
+type sensorStats struct {
+	sensor, sensorType, unit string
+	value                    float64
+	hasValue                 bool
+	ok, hasStatus            bool
+	tripPoints               []sensorTripPoint
+}
+
+func init() {
+	registerCollector("lnx_thermal", NewLnxThermalCollector)
+	registerCollector("ipmi_sensors", NewIpmiSensorsCollector)
+	registerCollector("lnx_sensors", NewLnxSensorsCollector)
+}
+
+func NewLnxThermalCollector() (Collector, error) {
+	return newSensorCollector("lnx_thermal"), nil
+}
+
+func NewIpmiSensorsCollector() (Collector, error) {
+	return newSensorCollector("ipmi_sensors"), nil
+}
+
+func NewLnxSensorsCollector() (Collector, error) {
+	return newSensorCollector("lnx_sensors"), nil
+}
+
+func newSensorCollector(source string) sensorCollector {
+	subsystem := "sensor"
+
+	ValueDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "value"),
+		"Current sensor reading",
+		sensorLabelNames, nil,
+	)
+	StatusDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "status"),
+		"Whether the sensor reports a nominal state (1) or not (0)",
+		sensorStatusLabelNames, nil,
+	)
+	TripPointDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "trip_point"),
+		"Sensor trip point threshold",
+		sensorTripPointLabelNames, nil,
+	)
+	return sensorCollector{
+		source:        source,
+		ValueDesc:     ValueDesc,
+		StatusDesc:    StatusDesc,
+		TripPointDesc: TripPointDesc,
+	}
+}
+
+func (c sensorCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	stats := c.parseStats(unparsedStats)
+
+	seen := make(map[string]struct{})
+	for _, s := range stats {
+		// sensor names are not guaranteed to be unique, e.g. in IPMI SDRs
+		if _, ok := seen[s.sensor]; ok {
+			log.Debugf("Skipping duplicate sensor '%s'", s.sensor)
+			continue
+		}
+		seen[s.sensor] = struct{}{}
+
+		if s.hasValue {
+			ch <- prometheus.MustNewConstMetric(
+				c.ValueDesc, prometheus.GaugeValue,
+				s.value, c.source, s.sensor, s.sensorType, s.unit,
+			)
+		}
+		if s.hasStatus {
+			status := 0.0
+			if s.ok {
+				status = 1
+			}
+			ch <- prometheus.MustNewConstMetric(
+				c.StatusDesc, prometheus.GaugeValue,
+				status, c.source, s.sensor, s.sensorType,
+			)
+		}
+		// trip points of the same type may repeat, e.g. several active ones
+		for i, t := range s.tripPoints {
+			ch <- prometheus.MustNewConstMetric(
+				c.TripPointDesc, prometheus.GaugeValue,
+				t.value, c.source, s.sensor, s.sensorType, s.unit, t.tripType, strconv.Itoa(i),
+			)
+		}
+	}
+	return nil
+}
+
+func (c sensorCollector) parseStats(unparsedStats *[]string) []sensorStats {
+	switch c.source {
+	case "lnx_thermal":
+		return parseLnxThermalStats(unparsedStats)
+	case "ipmi_sensors":
+		return parseIpmiSensorsStats(unparsedStats)
+	default:
+		return parseLnxSensorsStats(unparsedStats)
+	}
+}
+
+// parseLnxThermalStats handles the '|' separated thermal zones, with the
+// temperature and trip points in millidegrees Celsius:
+//
+//	thermal_zone0|enabled|acpitz|27800|105000|critical|80000|passive
+func parseLnxThermalStats(unparsedStats *[]string) []sensorStats {
+
+	stats := []sensorStats{}
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		fields := strings.Split(stat, "|")
+		if len(fields) < 4 {
+			log.Debugf("Skipping '%s'", stat)
+			continue
+		}
+		temp, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil {
+			log.Debugf("Skipping '%s': %s", stat, err)
+			continue
+		}
+		s := sensorStats{
+			sensor:     fields[0],
+			sensorType: "temperature",
+			unit:       "celsius",
+			value:      temp / 1000,
+			hasValue:   true,
+			ok:         true,
+			hasStatus:  fields[1] != "disabled",
+		}
+		for i := 4; i+1 < len(fields); i += 2 {
+			tripTemp, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				continue
+			}
+			t := sensorTripPoint{tripType: fields[i+1], value: tripTemp / 1000}
+			if (t.tripType == "critical" || t.tripType == "hot") && s.value >= t.value {
+				s.ok = false
+			}
+			s.tripPoints = append(s.tripPoints, t)
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// parseIpmiSensorsStats handles freeipmi's '|' separated sensor output:
+//
+//	ID|Name|Type|State|Reading|Units|Event
+func parseIpmiSensorsStats(unparsedStats *[]string) []sensorStats {
+
+	stats := []sensorStats{}
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		fields := strings.Split(stat, "|")
+		if len(fields) < 6 || fields[0] == "ID" {
+			log.Debugf("Skipping '%s'", stat)
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		s := sensorStats{
+			sensor:     fields[1],
+			sensorType: strings.Replace(strings.ToLower(fields[2]), " ", "_", -1),
+			unit:       ipmiUnits[fields[5]],
+		}
+		if s.unit == "" {
+			s.unit = "none"
+		}
+		if value, err := strconv.ParseFloat(fields[4], 64); err == nil {
+			s.value = value
+			s.hasValue = true
+		}
+		if fields[3] != "N/A" {
+			s.hasStatus = true
+			s.ok = fields[3] == "Nominal"
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// parseLnxSensorsStats handles lm-sensors' raw output ('sensors -u'), which
+// lists chips, their features and the features' subfeatures:
+//
+//	coretemp-isa-0000
+//	Adapter: ISA adapter
+//	Package id 0:
+//	  temp1_input: 42.000
+//	  temp1_crit: 100.000
+//	  temp1_crit_alarm: 0.000
+func parseLnxSensorsStats(unparsedStats *[]string) []sensorStats {
+
+	stats := []sensorStats{}
+	chip := ""
+	var current *sensorStats
+	flush := func() {
+		if current != nil && (current.hasValue || current.hasStatus) {
+			if current.sensorType == "" {
+				current.sensorType, current.unit = "other", "none"
+			}
+			stats = append(stats, *current)
+		}
+		current = nil
+	}
+
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		trimmed := strings.TrimSpace(stat)
+		switch {
+		case trimmed == "":
+			flush()
+			chip = ""
+		case !strings.HasPrefix(stat, " ") && !strings.Contains(stat, ":"):
+			flush()
+			chip = trimmed
+		case strings.HasPrefix(stat, "Adapter:"):
+			continue
+		case !strings.HasPrefix(stat, " ") && strings.HasSuffix(trimmed, ":"):
+			flush()
+			current = &sensorStats{sensor: chip + "/" + strings.TrimSuffix(trimmed, ":")}
+		case current != nil:
+			parts := strings.SplitN(trimmed, ":", 2)
+			match := lmSensorsSubfeature.FindStringSubmatch(parts[0])
+			if len(parts) != 2 || match == nil {
+				continue
+			}
+			value, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+			if err != nil {
+				continue
+			}
+			if typeAndUnit, ok := lmSensorsTypes[match[1]]; ok {
+				current.sensorType, current.unit = typeAndUnit[0], typeAndUnit[1]
+			}
+			switch {
+			case match[2] == "input":
+				current.value = value
+				current.hasValue = true
+			case strings.HasSuffix(match[2], "_alarm") || match[2] == "alarm" || match[2] == "fault":
+				current.ok = (!current.hasStatus || current.ok) && value == 0
+				current.hasStatus = true
+			case match[2] == "max" || match[2] == "min" || match[2] == "crit" || match[2] == "lcrit":
+				current.tripPoints = append(current.tripPoints, sensorTripPoint{tripType: match[2], value: value})
+			}
+		}
+	}
+	flush()
+	return stats
+}
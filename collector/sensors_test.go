@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"bytes"
+	"github.com/prometheus/client_golang/prometheus"
+	"io/ioutil"
+	"testing"
+)
+
+func TestSensorsParseStats(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/sensors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+
+	thermal := parseLnxThermalStats(structuredStats["lnx_thermal"])
+	if want, got := 3, len(thermal); want != got {
+		t.Fatalf("want %d thermal zones, got %d", want, got)
+	}
+	if want, got := 2, len(thermal[0].tripPoints); want != got {
+		t.Errorf("want %d trip points for thermal_zone0, got %d", want, got)
+	}
+	if !thermal[0].ok || thermal[1].ok {
+		t.Errorf("want only thermal_zone1 to exceed its trip point")
+	}
+
+	ipmi := parseIpmiSensorsStats(structuredStats["ipmi_sensors"])
+	if want, got := 4, len(ipmi); want != got {
+		t.Fatalf("want %d ipmi sensors, got %d", want, got)
+	}
+	if want, got := "power_supply", ipmi[2].sensorType; want != got {
+		t.Errorf("want type %s, got %s", want, got)
+	}
+	if ipmi[2].ok || ipmi[2].hasValue {
+		t.Errorf("want failed power supply without reading, got %+v", ipmi[2])
+	}
+
+	lmSensors := parseLnxSensorsStats(structuredStats["lnx_sensors"])
+	if want, got := 2, len(lmSensors); want != got {
+		t.Fatalf("want %d lm-sensors, got %d", want, got)
+	}
+	if want, got := "coretemp-isa-0000/Package id 0", lmSensors[0].sensor; want != got {
+		t.Errorf("want sensor %s, got %s", want, got)
+	}
+	if want, got := "fan", lmSensors[1].sensorType; want != got {
+		t.Errorf("want type %s, got %s", want, got)
+	}
+	if !lmSensors[0].ok || lmSensors[1].ok {
+		t.Errorf("want only fan2 to be alarming")
+	}
+}
+
+// sectionCollector runs a collector's Update on a section when gathered.
+type sectionCollector struct {
+	c     Collector
+	stats *[]string
+}
+
+func (s sectionCollector) Collect(ch chan<- prometheus.Metric) {
+	s.c.Update(s.stats, ch)
+}
+
+func (s sectionCollector) Describe(ch chan<- *prometheus.Desc) {
+	// unchecked, as CheckMKCollector
+}
+
+func TestSensorsUpdate(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/sensors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+	// the lm-sensors feature reported as thermal zone as well
+	thermal := append([]string{"coretemp-isa-0000/Package id 0|enabled|x86_pkg_temp|42000"}, *structuredStats["lnx_thermal"]...)
+	structuredStats["lnx_thermal"] = &thermal
+
+	// the sections share the metric families, so are gathered together
+	registry := prometheus.NewRegistry()
+	for section, factory := range map[string]func() (Collector, error){
+		"lnx_thermal":  NewLnxThermalCollector,
+		"ipmi_sensors": NewIpmiSensorsCollector,
+		"lnx_sensors":  NewLnxSensorsCollector,
+	} {
+		c, err := factory()
+		if err != nil {
+			t.Fatal(err)
+		}
+		registry.MustRegister(sectionCollector{c, structuredStats[section]})
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("want unique sensor series, got %s", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "check_mk_sensor_trip_point" {
+			continue
+		}
+		active := 0
+		for _, m := range family.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "trip_point" && l.GetValue() == "active" {
+					active++
+				}
+			}
+		}
+		if want, got := 2, active; want != got {
+			t.Errorf("want %d active trip points, got %d", want, got)
+		}
+	}
+}
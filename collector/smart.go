@@ -0,0 +1,215 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+var (
+	smartDeviceLabelNames    = []string{"device", "model"}
+	smartAttributeLabelNames = []string{"device", "model", "attribute_id", "attribute"}
+)
+
+type smartCollector struct {
+	AttributeValueDesc     *prometheus.Desc
+	AttributeWorstDesc     *prometheus.Desc
+	AttributeThresholdDesc *prometheus.Desc
+	AttributeRawValueDesc  *prometheus.Desc
+	HealthyDesc            *prometheus.Desc
+}
+
+type smartLabels struct {
+	device, model string
+}
+
+type smartAttribute struct {
+	id, name                     string
+	value, worst, threshold, raw float64
+	hasNormalized, failingNow    bool
+}
+
+type smartDevice struct {
+	labels     smartLabels
+	nvme       bool
+	attributes []smartAttribute
+}
+
+func init() {
+	registerCollector("smart", NewSmartCollector)
+}
+
+func NewSmartCollector() (Collector, error) {
+	subsystem := "smart"
+
+	AttributeValueDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "attribute_value"),
+		"SMART attribute normalized value",
+		smartAttributeLabelNames, nil,
+	)
+	AttributeWorstDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "attribute_worst"),
+		"SMART attribute worst normalized value",
+		smartAttributeLabelNames, nil,
+	)
+	AttributeThresholdDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "attribute_threshold"),
+		"SMART attribute threshold for the normalized value",
+		smartAttributeLabelNames, nil,
+	)
+	AttributeRawValueDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "attribute_raw_value"),
+		"SMART attribute raw value",
+		smartAttributeLabelNames, nil,
+	)
+	HealthyDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "device_healthy"),
+		"Whether no SMART attribute of the device is failing (1) or not (0)",
+		smartDeviceLabelNames, nil,
+	)
+	return smartCollector{
+		AttributeValueDesc:     AttributeValueDesc,
+		AttributeWorstDesc:     AttributeWorstDesc,
+		AttributeThresholdDesc: AttributeThresholdDesc,
+		AttributeRawValueDesc:  AttributeRawValueDesc,
+		HealthyDesc:            HealthyDesc,
+	}, nil
+}
+
+func (c smartCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	devices := c.parseStats(unparsedStats)
+
+	for _, d := range devices {
+		healthy := 1.0
+		if !d.healthy() {
+			healthy = 0
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.HealthyDesc, prometheus.GaugeValue,
+			healthy, d.labels.device, d.labels.model,
+		)
+		for _, a := range d.attributes {
+			if a.hasNormalized {
+				ch <- prometheus.MustNewConstMetric(
+					c.AttributeValueDesc, prometheus.GaugeValue,
+					a.value, d.labels.device, d.labels.model, a.id, a.name,
+				)
+				ch <- prometheus.MustNewConstMetric(
+					c.AttributeWorstDesc, prometheus.GaugeValue,
+					a.worst, d.labels.device, d.labels.model, a.id, a.name,
+				)
+				ch <- prometheus.MustNewConstMetric(
+					c.AttributeThresholdDesc, prometheus.GaugeValue,
+					a.threshold, d.labels.device, d.labels.model, a.id, a.name,
+				)
+			}
+			ch <- prometheus.MustNewConstMetric(
+				c.AttributeRawValueDesc, prometheus.GaugeValue,
+				a.raw, d.labels.device, d.labels.model, a.id, a.name,
+			)
+		}
+	}
+	return nil
+}
+
+// healthy mirrors smartctl's overall assessment: ATA attributes fail once
+// their normalized value drops to the threshold, NVMe devices report a
+// critical warning bitmask and the spare capacity left.
+func (d smartDevice) healthy() bool {
+	if d.nvme {
+		values := make(map[string]float64)
+		for _, a := range d.attributes {
+			values[a.name] = a.raw
+		}
+		return values["critical_warning"] == 0 &&
+			values["available_spare"] >= values["available_spare_threshold"]
+	}
+	for _, a := range d.attributes {
+		if a.failingNow || (a.threshold > 0 && a.value <= a.threshold) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseStats handles both the ATA attribute table, one attribute per line
+// prefixed with device, vendor and model:
+//
+//	/dev/sda ATA WDC_WD20EFRX-68E 5 Reallocated_Sector_Ct 0x0033 200 200 140 Pre-fail Always - 0
+//
+// and NVMe devices, introduced by a device line or [[[device]]] subsection
+// header and followed by smartctl's 'key: value' health information:
+//
+//	/dev/nvme0n1 NVME SAMSUNG_MZVLB512HAJQ-000L7
+//	Critical Warning:                   0x00
+//	Available Spare:                    100%
+func (c smartCollector) parseStats(unparsedStats *[]string) []smartDevice {
+
+	devices := []smartDevice{}
+	index := make(map[string]int)
+	device := func(labels smartLabels, nvme bool) *smartDevice {
+		if i, ok := index[labels.device]; ok {
+			return &devices[i]
+		}
+		index[labels.device] = len(devices)
+		devices = append(devices, smartDevice{labels: labels, nvme: nvme})
+		return &devices[len(devices)-1]
+	}
+
+	var nvmeDevice string
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		line := strings.TrimSuffix(strings.TrimPrefix(stat, "[[["), "]]]")
+		fields := strings.Fields(line)
+
+		switch {
+		case len(fields) == 3 && fields[1] == "NVME":
+			nvmeDevice = fields[0]
+			device(smartLabels{device: fields[0], model: fields[2]}, true)
+		case len(fields) >= 13 && strings.HasPrefix(fields[0], "/dev/"):
+			nvmeDevice = ""
+			value, _ := strconv.ParseFloat(fields[6], 64)
+			worst, _ := strconv.ParseFloat(fields[7], 64)
+			threshold, _ := strconv.ParseFloat(fields[8], 64)
+			d := device(smartLabels{device: fields[0], model: fields[2]}, false)
+			d.attributes = append(d.attributes, smartAttribute{
+				id:            fields[3],
+				name:          fields[4],
+				value:         value,
+				worst:         worst,
+				threshold:     threshold,
+				raw:           parseSmartRawValue(fields[12]),
+				hasNormalized: true,
+				failingNow:    fields[11] == "FAILING_NOW",
+			})
+		case nvmeDevice != "" && strings.Contains(line, ":"):
+			parts := strings.SplitN(line, ":", 2)
+			name := strings.ToLower(strings.Join(strings.Fields(parts[0]), "_"))
+			d := &devices[index[nvmeDevice]]
+			d.attributes = append(d.attributes, smartAttribute{
+				name: name,
+				raw:  parseSmartRawValue(strings.TrimSpace(parts[1])),
+			})
+		default:
+			log.Debugf("Skipping '%s'", stat)
+		}
+	}
+	return devices
+}
+
+// parseSmartRawValue reads the leading number of values such as '0x00',
+// '39 Celsius', '100%' or '1,478,023 [756 GB]'.
+func parseSmartRawValue(raw string) float64 {
+	fields := strings.Fields(raw)
+	if len(fields) == 0 {
+		return 0
+	}
+	number := strings.TrimSuffix(strings.Replace(fields[0], ",", "", -1), "%")
+	if strings.HasPrefix(number, "0x") {
+		value, _ := strconv.ParseInt(number[2:], 16, 64)
+		return float64(value)
+	}
+	value, _ := strconv.ParseFloat(number, 64)
+	return value
+}
@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestSmartParseStats(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/smart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+
+	devices := smartCollector{}.parseStats(structuredStats["smart"])
+	if want, got := 4, len(devices); want != got {
+		t.Fatalf("want %d devices, got %d", want, got)
+	}
+
+	healthy := map[string]bool{
+		"/dev/sda":     true,
+		"/dev/sdb":     false,
+		"/dev/nvme0n1": true,
+		"/dev/nvme1n1": false,
+	}
+	for _, d := range devices {
+		if want, got := healthy[d.labels.device], d.healthy(); want != got {
+			t.Errorf("want healthy %t for %s, got %t", want, d.labels.device, got)
+		}
+	}
+
+	if want, got := 7, len(devices[2].attributes); want != got {
+		t.Errorf("want %d attributes for %s, got %d", want, devices[2].labels.device, got)
+	}
+	if want, got := 1478023.0, devices[3].attributes[5].raw; want != got {
+		t.Errorf("want raw value %f for %s, got %f", want, devices[3].attributes[5].name, got)
+	}
+	if want, got := 31.0, devices[0].attributes[2].raw; want != got {
+		t.Errorf("want raw value %f for %s, got %f", want, devices[0].attributes[2].name, got)
+	}
+}
@@ -0,0 +1,203 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timesyncLabelNames          = []string{"source"}
+	timesyncReferenceLabelNames = []string{"source", "reference"}
+)
+
+// timesyncCollector normalises the chrony, ntp and timesyncd sections into a
+// single metric family, distinguished by the 'source' label.
+type timesyncCollector struct {
+	source        string
+	OffsetDesc    *prometheus.Desc
+	StratumDesc   *prometheus.Desc
+	ReferenceDesc *prometheus.Desc
+	SyncedDesc    *prometheus.Desc
+}
+
+type timesyncStats struct {
+	offset, stratum float64
+	reference       string
+	synced          bool
+}
+
+func init() {
+	registerCollector("chrony", NewChronyCollector)
+	registerCollector("ntp", NewNtpCollector)
+	registerCollector("timesyncd", NewTimesyncdCollector)
+}
+
+func NewChronyCollector() (Collector, error) {
+	return newTimesyncCollector("chrony"), nil
+}
+
+func NewNtpCollector() (Collector, error) {
+	return newTimesyncCollector("ntp"), nil
+}
+
+func NewTimesyncdCollector() (Collector, error) {
+	return newTimesyncCollector("timesyncd"), nil
+}
+
+func newTimesyncCollector(source string) timesyncCollector {
+	subsystem := "timesync"
+
+	OffsetDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "offset_seconds"),
+		"Offset of the system clock to the reference time, negative when the clock is behind",
+		timesyncLabelNames, nil,
+	)
+	StratumDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "stratum"),
+		"Stratum of the system clock",
+		timesyncLabelNames, nil,
+	)
+	ReferenceDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "reference_info"),
+		"Reference the system clock is synchronised to",
+		timesyncReferenceLabelNames, nil,
+	)
+	SyncedDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "synchronized"),
+		"Whether the system clock is synchronised (1) or not (0)",
+		timesyncLabelNames, nil,
+	)
+	return timesyncCollector{
+		source:        source,
+		OffsetDesc:    OffsetDesc,
+		StratumDesc:   StratumDesc,
+		ReferenceDesc: ReferenceDesc,
+		SyncedDesc:    SyncedDesc,
+	}
+}
+
+func (t timesyncCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	s := t.parseStats(unparsedStats)
+
+	synced := 0.0
+	if s.synced {
+		synced = 1
+	}
+	ch <- prometheus.MustNewConstMetric(
+		t.SyncedDesc, prometheus.GaugeValue, synced, t.source,
+	)
+	if !s.synced {
+		return nil
+	}
+	ch <- prometheus.MustNewConstMetric(
+		t.OffsetDesc, prometheus.GaugeValue, s.offset, t.source,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		t.StratumDesc, prometheus.GaugeValue, s.stratum, t.source,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		t.ReferenceDesc, prometheus.GaugeValue, 1, t.source, s.reference,
+	)
+	return nil
+}
+
+func (t timesyncCollector) parseStats(unparsedStats *[]string) timesyncStats {
+	switch t.source {
+	case "chrony":
+		return parseChronyStats(unparsedStats)
+	case "ntp":
+		return parseNtpStats(unparsedStats)
+	default:
+		return parseTimesyncdStats(unparsedStats)
+	}
+}
+
+// keyValueStats splits 'key : value' lines as printed by chronyc and timedatectl.
+func keyValueStats(unparsedStats *[]string) map[string]string {
+	values := make(map[string]string)
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		parts := strings.SplitN(stat, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		values[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return values
+}
+
+// parseChronyStats handles 'chronyc -n tracking' output, e.g.
+//
+//	Reference ID    : C0248F97 (192.36.143.151)
+//	Stratum         : 2
+//	System time     : 0.000012345 seconds slow of NTP time
+//	Leap status     : Normal
+func parseChronyStats(unparsedStats *[]string) timesyncStats {
+	values := keyValueStats(unparsedStats)
+
+	stats := timesyncStats{}
+	if fields := strings.Fields(values["Reference ID"]); len(fields) > 0 {
+		stats.reference = strings.Trim(fields[len(fields)-1], "()")
+	}
+	stats.stratum, _ = strconv.ParseFloat(values["Stratum"], 64)
+	if fields := strings.Fields(values["System time"]); len(fields) > 2 {
+		stats.offset, _ = strconv.ParseFloat(fields[0], 64)
+		if fields[2] == "slow" {
+			stats.offset = -stats.offset
+		}
+	}
+	stats.synced = values["Leap status"] != "" &&
+		values["Leap status"] != "Not synchronised" &&
+		stats.stratum > 0
+	return stats
+}
+
+// parseNtpStats handles 'ntpq -np' peer lines, of which the one marked as
+// system peer ('*' or 'o' in the first column) determines the clock's state.
+// Offsets are reported in milliseconds.
+func parseNtpStats(unparsedStats *[]string) timesyncStats {
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		fields := strings.Fields(stat)
+		if len(fields) < 11 || (fields[0] != "*" && fields[0] != "o") {
+			continue
+		}
+		peerStratum, _ := strconv.ParseFloat(fields[3], 64)
+		offset, _ := strconv.ParseFloat(fields[9], 64)
+		return timesyncStats{
+			reference: fields[1],
+			stratum:   peerStratum + 1,
+			offset:    offset / 1000,
+			synced:    true,
+		}
+	}
+	return timesyncStats{}
+}
+
+var timesyncdMinutes = regexp.MustCompile(`(\d+)min`)
+
+// parseTimesyncdStats handles 'timedatectl timesync-status' output, e.g.
+//
+//	   Server: 192.36.143.151 (ntp.example.com)
+//	  Stratum: 2
+//	Reference: C0248F97
+//	   Offset: -1.091ms
+func parseTimesyncdStats(unparsedStats *[]string) timesyncStats {
+	values := keyValueStats(unparsedStats)
+
+	stats := timesyncStats{reference: values["Reference"]}
+	stats.stratum, _ = strconv.ParseFloat(values["Stratum"], 64)
+	// timedatectl prints durations like '+1.2ms', '35us' or '1min 2s'
+	offset := timesyncdMinutes.ReplaceAllString(values["Offset"], "${1}m")
+	if d, err := time.ParseDuration(strings.Replace(offset, " ", "", -1)); err == nil {
+		stats.offset = d.Seconds()
+	}
+	stats.synced = values["Server"] != "" &&
+		values["Leap"] != "not synchronized" &&
+		stats.stratum > 0
+	return stats
+}
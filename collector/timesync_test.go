@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestChronyParseStats(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/chrony")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+
+	stats := parseChronyStats(structuredStats["chrony"])
+	if !stats.synced {
+		t.Errorf("want chrony to be synchronised")
+	}
+	if want, got := "192.36.143.151", stats.reference; want != got {
+		t.Errorf("want reference %s, got %s", want, got)
+	}
+	if want, got := -0.000012345, stats.offset; want != got {
+		t.Errorf("want offset %f, got %f", want, got)
+	}
+}
+
+func TestNtpParseStats(t *testing.T) {
+	lines := []string{
+		"+ 10.0.0.2        .GPS.            1 u   35   64  377    0.412    0.201   0.021",
+		"* 192.36.143.151  .PPS.            1 u  112 1024  377    8.263   -0.312   0.195",
+	}
+	stats := parseNtpStats(&lines)
+	if want, got := "192.36.143.151", stats.reference; want != got {
+		t.Errorf("want reference %s, got %s", want, got)
+	}
+	if want, got := 2.0, stats.stratum; want != got {
+		t.Errorf("want stratum %f, got %f", want, got)
+	}
+	if want, got := -0.000312, stats.offset; want != got {
+		t.Errorf("want offset %f, got %f", want, got)
+	}
+}
+
+func TestTimesyncdParseStats(t *testing.T) {
+	lines := []string{
+		"       Server: 192.36.143.151 (ntp.example.com)",
+		"Poll interval: 34min 8s (min: 32s; max 34min 8s)",
+		"         Leap: normal",
+		"      Stratum: 2",
+		"    Reference: C0248F97",
+		"       Offset: -1.091ms",
+	}
+	stats := parseTimesyncdStats(&lines)
+	if !stats.synced {
+		t.Errorf("want timesyncd to be synchronised")
+	}
+	if want, got := -0.001091, stats.offset; want != got {
+		t.Errorf("want offset %f, got %f", want, got)
+	}
+}
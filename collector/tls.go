@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/bverschueren/check_mk_exporter/config"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	// sent by the agent controller before the TLS handshake, any other
+	// output is of an agent without the controller
+	agentCtlTLSMarker = "16"
+
+	// the header of the agent controller's message
+	agentCtlVersion           = 0
+	agentCtlUncompressed      = 0
+	agentCtlZlib              = 1
+	agentCtlHeaderLength      = 3
+	agentCtlConnectionTimeout = 10 * time.Second
+	agentCtlTimeout           = 60 * time.Second
+)
+
+// collectTLSRawStats fetches the output of agents registered with the Checkmk
+// agent controller (cmk-agent-ctl), which serves it over TLS with client
+// certificate authentication.
+func (mc CheckMKCollector) collectTLSRawStats() (*bytes.Buffer, error) {
+	log.Debugf("Collecting stats from %s over TLS", mc.target.HostName)
+
+	tlsConfig, err := agentCtlTLSConfig(mc.target)
+	if err != nil {
+		log.Errorf("Unable to load TLS certificates for '%s': %s", mc.target.Name, err)
+		return nil, err
+	}
+
+	address := net.JoinHostPort(mc.target.HostName, strconv.Itoa(mc.target.Port))
+	conn, err := net.DialTimeout("tcp", address, agentCtlConnectionTimeout)
+	if err != nil {
+		log.Infof("Unable to collect stats from '%s': %s", mc.target.HostName, err)
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(agentCtlTimeout))
+
+	marker := make([]byte, len(agentCtlTLSMarker))
+	if _, err := io.ReadFull(conn, marker); err != nil {
+		return nil, fmt.Errorf("unable to read protocol of '%s': %s", mc.target.HostName, err)
+	}
+	if string(marker) != agentCtlTLSMarker {
+		// not registered yet, the agent sends its (possibly encrypted) output as is
+		log.Warnf("Agent of '%s' doesn't use TLS, is it registered?", mc.target.HostName)
+		raw := bytes.NewBuffer(marker)
+		_, err := raw.ReadFrom(conn)
+		return raw, err
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		log.Errorf("TLS handshake with '%s' failed: %s", mc.target.HostName, err)
+		return nil, err
+	}
+	message, err := ioutil.ReadAll(tlsConn)
+	if err != nil && len(message) == 0 {
+		return nil, err
+	}
+	raw, err := decodeAgentCtlMessage(message)
+	if err != nil {
+		return nil, err
+	}
+	log.Trace("Raw check_mk stats: " + raw.String())
+	return raw, nil
+}
+
+// agentCtlTLSConfig authenticates by the client certificate registered with
+// the agent, which in turn has to present a certificate for its UUID (or
+// host name) signed by the CA.
+func agentCtlTLSConfig(target config.Target) (*tls.Config, error) {
+	ca, err := ioutil.ReadFile(target.TLSCAFile)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates found in '%s'", target.TLSCAFile)
+	}
+	cert, err := tls.LoadX509KeyPair(target.TLSCertFile, target.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	serverName := target.TLSServerName
+	if serverName == "" {
+		serverName = target.HostName
+	}
+	return &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{cert},
+		ServerName:   serverName,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// decodeAgentCtlMessage handles the agent controller's framing, a 2 byte
+// version and a 1 byte compression type followed by the payload:
+//
+//	00 00 01 <zlib compressed agent output>
+func decodeAgentCtlMessage(message []byte) (*bytes.Buffer, error) {
+	if len(message) < agentCtlHeaderLength {
+		return nil, fmt.Errorf("agent controller message too short (%d bytes)", len(message))
+	}
+	version := int(message[0])<<8 | int(message[1])
+	if version != agentCtlVersion {
+		return nil, fmt.Errorf("unsupported agent controller protocol version %d", version)
+	}
+	payload := message[agentCtlHeaderLength:]
+	switch message[2] {
+	case agentCtlUncompressed:
+		return bytes.NewBuffer(payload), nil
+	case agentCtlZlib:
+		r, err := zlib.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		raw := new(bytes.Buffer)
+		if _, err := raw.ReadFrom(r); err != nil {
+			return nil, err
+		}
+		return raw, nil
+	default:
+		return nil, fmt.Errorf("unsupported agent controller compression type %d", message[2])
+	}
+}
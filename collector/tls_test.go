@@ -0,0 +1,163 @@
+package collector
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/bverschueren/check_mk_exporter/config"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// agentCtlStandIn serves a single connection like an agent registered with
+// the agent controller, requiring a client certificate signed by the CA.
+func agentCtlStandIn(t *testing.T, ca *x509.Certificate, cert tls.Certificate, output []byte) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte(agentCtlTLSMarker))
+		tlsConn := tls.Server(conn, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientCAs:    clientCAs,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		})
+		if err := tlsConn.Handshake(); err != nil {
+			return
+		}
+		var compressed bytes.Buffer
+		w := zlib.NewWriter(&compressed)
+		w.Write(output)
+		w.Close()
+		tlsConn.Write(append([]byte{0, agentCtlVersion, agentCtlZlib}, compressed.Bytes()...))
+		tlsConn.Close()
+	}()
+	return l
+}
+
+// issueCert writes a certificate and key for name signed by the CA (or self
+// signed without one) to dir.
+func issueCert(t *testing.T, dir, name string, ca *x509.Certificate, caKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, tls.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if ca == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		ca, caKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	ioutil.WriteFile(filepath.Join(dir, name+".pem"), certPEM, 0600)
+	ioutil.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600)
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key, pair
+}
+
+func TestCollectTLSRawStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "agentctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, caKey, _ := issueCert(t, dir, "ca", nil, nil)
+	_, _, agentCert := issueCert(t, dir, "agent-uuid", ca, caKey)
+	issueCert(t, dir, "site", ca, caKey)
+	issueCert(t, dir, "unregistered", nil, nil)
+
+	output, err := ioutil.ReadFile("../testdata/check_mk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		client string
+		ok     bool
+	}{
+		{"site", true},
+		{"unregistered", false},
+	} {
+		l := agentCtlStandIn(t, ca, agentCert, output)
+		port := l.Addr().(*net.TCPAddr).Port
+		mc := CheckMKCollector{target: config.Target{
+			HostName:      "127.0.0.1",
+			Port:          port,
+			Transport:     "tls",
+			TLSCAFile:     filepath.Join(dir, "ca.pem"),
+			TLSCertFile:   filepath.Join(dir, tc.client+".pem"),
+			TLSKeyFile:    filepath.Join(dir, tc.client+".key"),
+			TLSServerName: "agent-uuid",
+		}}
+		raw, err := mc.collectRawStats()
+		l.Close()
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("want an error for client certificate '%s'", tc.client)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("want the agent output for client certificate '%s', got %s", tc.client, err)
+		}
+		if !bytes.Equal(output, raw.Bytes()) {
+			t.Errorf("want the decompressed agent output, got %q", raw.String())
+		}
+	}
+}
+
+func TestDecodeAgentCtlMessage(t *testing.T) {
+	raw, err := decodeAgentCtlMessage([]byte("\x00\x00\x00<<<check_mk>>>\n"))
+	if err != nil || raw.String() != "<<<check_mk>>>\n" {
+		t.Errorf("want the uncompressed payload, got %q (%v)", raw, err)
+	}
+	for _, message := range []string{"\x00", "\x00\x01\x00<<<check_mk>>>", "\x00\x00\x07<<<check_mk>>>", "\x00\x00\x01garbage"} {
+		if _, err := decodeAgentCtlMessage([]byte(message)); err == nil {
+			t.Errorf("want an error for message %q", message)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/bverschueren/check_mk_exporter/config"
+)
+
+func readAgentOutput(t *testing.T, path string) *map[string]*[]string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return structureRawStats(bytes.NewBuffer(content))
+}
+
+func TestUnixDf(t *testing.T) {
+	mc, _ := NewMKCheckCollector(config.Target{})
+
+	for _, c := range []struct {
+		agentOS    string
+		want       int
+		mountPoint string
+		size       float64
+	}{
+		{"aix", 2, "/usr", 6291456},
+		{"solaris", 3, "/", 30707712},
+		{"freebsd", 2, "/var", 35214600},
+	} {
+		structuredStats := readAgentOutput(t, "../testdata/"+c.agentOS+"/agent_output")
+		if got := agentOS(structuredStats); c.agentOS != got {
+			t.Fatalf("want agent OS %s, got %s", c.agentOS, got)
+		}
+		df := mc.collectorsFor(c.agentOS)["df"].(dfCollector)
+		stats := df.parseStats((*structuredStats)["df"])
+		if got := len(stats); c.want != got {
+			t.Fatalf("want %d %s filesystems, got %d", c.want, c.agentOS, got)
+		}
+		found := false
+		for _, s := range stats {
+			if s.labels.mountPoint == c.mountPoint {
+				found = true
+				if c.size != s.size {
+					t.Errorf("want size %f for %s on %s, got %f", c.size, c.mountPoint, c.agentOS, s.size)
+				}
+				if c.agentOS == "aix" && s.labels.fsType != "-" {
+					t.Errorf("want the filesystem type placeholder of aix, got '%s'", s.labels.fsType)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("want filesystem %s on %s", c.mountPoint, c.agentOS)
+		}
+	}
+}
+
+func TestUnwrapSolarisDf(t *testing.T) {
+	lines := []string{
+		"rpool/ROOT/solaris",
+		"                     30707712 12488837 13946236    48%    /",
+		"[df_inodes_start]",
+		"swap",
+		"swap                 4194304       8  4194296     1%    /tmp",
+		"rpool/export",
+		"30707712 32 13946236 1% /export",
+		"[df_inodes_end]",
+	}
+	want := []string{
+		"rpool/ROOT/solaris 30707712 12488837 13946236    48%    /",
+		"[df_inodes_start]",
+		"swap",
+		"swap                 4194304       8  4194296     1%    /tmp",
+		"rpool/export 30707712 32 13946236 1% /export",
+		"[df_inodes_end]",
+	}
+	got := *unwrapSolarisDf(&lines)
+	if len(want) != len(got) {
+		t.Fatalf("want %d lines, got %d: %q", len(want), len(got), got)
+	}
+	for i := range want {
+		if want[i] != got[i] {
+			t.Errorf("want line %q, got %q", want[i], got[i])
+		}
+	}
+}
+
+func TestUnixCpu(t *testing.T) {
+	mc, _ := NewMKCheckCollector(config.Target{})
+
+	for _, c := range []struct {
+		path, agentOS string
+		load1, count  float64
+	}{
+		{"../testdata/aix/agent_output", "aix", 1.58, 4},
+		{"../testdata/solaris/agent_output", "solaris", 0.03, 8},
+		{"../testdata/freebsd/agent_output", "freebsd", 0.21, 4},
+	} {
+		structuredStats := readAgentOutput(t, c.path)
+		cpu := mc.collectorsFor(c.agentOS)["cpu"].(cpuCollector)
+		s, ok := parseCpuStats((*structuredStats)["cpu"], cpu.format)
+		if !ok {
+			t.Fatalf("want cpu stats for %s", c.agentOS)
+		}
+		if c.load1 != s.load[0] || !s.hasCount || c.count != s.count {
+			t.Errorf("want load %f and %f CPUs for %s, got %+v", c.load1, c.count, c.agentOS, s)
+		}
+	}
+}
+
+func TestUnixMem(t *testing.T) {
+	for _, c := range []struct {
+		path, section string
+		stats         func(*[]string) []memStats
+		memTotal      float64
+		swapFree      float64
+	}{
+		{"../testdata/freebsd/agent_output", "mem", parseMeminfoStats, 4148472 * 1024, 2097152 * 1024},
+		{"../testdata/solaris/agent_output", "statgrab_mem", parseStatgrabMemStats, 4294967296, 4294967296},
+		{"../testdata/aix/agent_output", "aix_memory", parseAixMemoryStats, 2097152 * 4096, 2016898 * 4096},
+	} {
+		structuredStats := readAgentOutput(t, c.path)
+		stats := c.stats((*structuredStats)[c.section])
+		if want, got := 2, len(stats); want != got {
+			t.Fatalf("want %d memory types in %s, got %d", want, c.path, got)
+		}
+		mem, swap := memStatsFor(&stats, "mem"), memStatsFor(&stats, "swap")
+		if c.memTotal != mem.total || c.swapFree != swap.free {
+			t.Errorf("want %f total memory and %f free swap in %s, got %+v and %+v", c.memTotal, c.swapFree, c.path, mem, swap)
+		}
+	}
+}
+
+func TestUnixCollectorDispatch(t *testing.T) {
+	mc, _ := NewMKCheckCollector(config.Target{})
+	for _, section := range []string{"cpu", "kernel", "mem", "statgrab_mem"} {
+		if _, ok := mc.collectorsFor("linux")[section]; ok {
+			t.Errorf("want no %s collector for Linux agents", section)
+		}
+	}
+	if _, ok := mc.collectorsFor("solaris")["kernel"].(kernelCollector); !ok {
+		t.Error("want the kernel collector for Solaris agents")
+	}
+}
+
+func TestKernel(t *testing.T) {
+	for _, c := range []struct {
+		agentOS         string
+		user            float64
+		contextSwitches float64
+		metrics         int
+	}{
+		// 2 CPUs summed up
+		{"solaris", (123456 + 120000) / 100.0, 2450765 + 2400000, len(kstatCpuModes) + 3},
+		// the statistics clock of kern.clockrate
+		{"freebsd", 4064 / 127.0, 2450765, len(freebsdCpuModes) + 2},
+	} {
+		structuredStats := readAgentOutput(t, "../testdata/"+c.agentOS+"/agent_output")
+		mc, _ := NewMKCheckCollector(config.Target{})
+		kernel := mc.collectorsFor(c.agentOS)["kernel"].(kernelCollector)
+
+		var s kernelStats
+		if c.agentOS == "freebsd" {
+			s = parseFreebsdKernelStats((*structuredStats)["kernel"])
+		} else {
+			s = parseSolarisKernelStats((*structuredStats)["kernel"])
+		}
+		if c.user != s.cpu["user"] || c.contextSwitches != s.contextSwitches {
+			t.Errorf("want %f user seconds and %f context switches on %s, got %+v", c.user, c.contextSwitches, c.agentOS, s)
+		}
+		metrics, err := collectMetrics(kernel, (*structuredStats)["kernel"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(metrics); c.metrics != got {
+			t.Errorf("want %d kernel metrics on %s, got %d", c.metrics, c.agentOS, got)
+		}
+	}
+
+	structuredStats := readAgentOutput(t, "../testdata/aix/agent_output")
+	metrics, err := collectMetrics(mustCollector(t, NewVmstatAixCollector), (*structuredStats)["vmstat_aix"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := len(vmstatAixCpuModes)+2, len(metrics); want != got {
+		t.Errorf("want %d vmstat metrics, got %d", want, got)
+	}
+}
@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestApacheParseStats(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/webserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+
+	stats := apacheCollector{}.parseStats(structuredStats["apache_status"])
+	if want, got := 4, len(stats); want != got {
+		t.Fatalf("want %d servers, got %d", want, got)
+	}
+	if want, got := 1530.0, stats[0].values["Total kBytes"]; want != got {
+		t.Errorf("want %f kBytes, got %f", want, got)
+	}
+	if want, got := 7.0, stats[0].scoreboard["waiting"]; want != got {
+		t.Errorf("want %f waiting slots, got %f", want, got)
+	}
+	if want, got := (apacheServer{"127.0.0.1", "80", ""}), stats[0].server; want != got {
+		t.Errorf("want server %v, got %v", want, got)
+	}
+	if want, got := (apacheServer{"127.0.0.1", "443", "www"}), stats[1].server; want != got {
+		t.Errorf("want server %v, got %v", want, got)
+	}
+	if want, got := 102.0, stats[1].values["Total Accesses"]; want != got {
+		t.Errorf("want %f accesses for instance, got %f", want, got)
+	}
+	// instances sharing the address and port are kept apart
+	if want, got := (apacheServer{"127.0.0.1", "443", "shop"}), stats[2].server; want != got {
+		t.Errorf("want server %v, got %v", want, got)
+	}
+	if want, got := 57.0, stats[2].values["Total Accesses"]; want != got {
+		t.Errorf("want %f accesses for instance, got %f", want, got)
+	}
+	if want, got := "::1", stats[3].server.address; want != got {
+		t.Errorf("want address %s, got %s", want, got)
+	}
+}
+
+func TestNginxParseStats(t *testing.T) {
+	content, err := ioutil.ReadFile("../testdata/webserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structuredStats := (*structureRawStats(bytes.NewBuffer(content)))
+
+	stats := nginxCollector{}.parseStats(structuredStats["nginx_status"])
+	if want, got := 1, len(stats); want != got {
+		t.Fatalf("want %d servers, got %d", want, got)
+	}
+	s := stats[0]
+	if !s.hasCounters || s.requests != 2467 || s.accepted != 1021 {
+		t.Errorf("want counters 1021 accepted and 2467 requests, got %+v", s)
+	}
+	if want, got := 3.0, s.active; want != got {
+		t.Errorf("want %f active connections, got %f", want, got)
+	}
+	if want, got := 2.0, s.states["waiting"]; want != got {
+		t.Errorf("want %f waiting connections, got %f", want, got)
+	}
+}
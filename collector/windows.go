@@ -0,0 +1,218 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	windowsMemLabelNames     = []string{"type"}
+	windowsServiceLabelNames = []string{"service", "start_type", "state"}
+
+	// the service states reported by the Windows agent
+	windowsServiceStates = []string{
+		"running", "stopped", "start_pending", "stop_pending",
+		"continuing", "pausing", "paused", "unknown",
+	}
+
+	wmiSubsection = regexp.MustCompile(`^\[(\w+)\]$`)
+)
+
+type windowsMemCollector struct {
+	TotalDesc *prometheus.Desc
+	FreeDesc  *prometheus.Desc
+}
+
+type windowsServicesCollector struct {
+	StateDesc *prometheus.Desc
+}
+
+type wmiCpuloadCollector struct {
+	QueueLengthDesc        *prometheus.Desc
+	LogicalProcessorsDesc  *prometheus.Desc
+	PhysicalProcessorsDesc *prometheus.Desc
+}
+
+func init() {
+	registerOSCollector("windows", "mem", NewWindowsMemCollector)
+	registerOSCollector("windows", "services", NewWindowsServicesCollector)
+	registerOSCollector("windows", "wmi_cpuload", NewWmiCpuloadCollector)
+}
+
+func NewWindowsMemCollector() (Collector, error) {
+	subsystem := "mem"
+
+	TotalDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "total_bytes"),
+		"Total memory by type",
+		windowsMemLabelNames, nil,
+	)
+	FreeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "free_bytes"),
+		"Free memory by type",
+		windowsMemLabelNames, nil,
+	)
+	return windowsMemCollector{
+		TotalDesc: TotalDesc,
+		FreeDesc:  FreeDesc,
+	}, nil
+}
+
+// Update handles the Windows variant of the mem section, e.g.
+//
+//	MemTotal:        8291456 kB
+//	PageFree:        5461504 kB
+func (m windowsMemCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	for key, value := range keyValueStats(unparsedStats) {
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		kbytes, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		var desc *prometheus.Desc
+		var memType string
+		switch {
+		case strings.HasSuffix(key, "Total"):
+			desc, memType = m.TotalDesc, strings.TrimSuffix(key, "Total")
+		case strings.HasSuffix(key, "Free"):
+			desc, memType = m.FreeDesc, strings.TrimSuffix(key, "Free")
+		default:
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			desc, prometheus.GaugeValue, kbytes*1024, strings.ToLower(memType),
+		)
+	}
+	return nil
+}
+
+func NewWindowsServicesCollector() (Collector, error) {
+	StateDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "service", "state"),
+		"Whether the service is in the state (1) or not (0)",
+		windowsServiceLabelNames, nil,
+	)
+	return windowsServicesCollector{
+		StateDesc: StateDesc,
+	}, nil
+}
+
+// Update handles the services as '<name> <state>/<start type> <description>':
+//
+//	AeLookupSvc running/demand Application_Experience
+func (s windowsServicesCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	seen := make(map[string]struct{})
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		fields := strings.Fields(stat)
+		if len(fields) < 2 {
+			continue
+		}
+		if _, ok := seen[fields[0]]; ok {
+			continue
+		}
+		seen[fields[0]] = struct{}{}
+		parts := strings.SplitN(fields[1], "/", 2)
+		state, startType := parts[0], ""
+		if len(parts) == 2 {
+			startType = parts[1]
+		}
+		known := false
+		for _, st := range windowsServiceStates {
+			known = known || st == state
+			ch <- prometheus.MustNewConstMetric(
+				s.StateDesc, prometheus.GaugeValue, boolToFloat(st == state), fields[0], startType, st,
+			)
+		}
+		if !known {
+			log.Debugf("Unknown state '%s' of service '%s'", state, fields[0])
+		}
+	}
+	return nil
+}
+
+func NewWmiCpuloadCollector() (Collector, error) {
+	subsystem := "wmi_cpuload"
+
+	QueueLengthDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "processor_queue_length"),
+		"Number of threads waiting for processor time",
+		nil, nil,
+	)
+	LogicalProcessorsDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "logical_processors"),
+		"Number of logical processors",
+		nil, nil,
+	)
+	PhysicalProcessorsDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "processors"),
+		"Number of physical processors",
+		nil, nil,
+	)
+	return wmiCpuloadCollector{
+		QueueLengthDesc:        QueueLengthDesc,
+		LogicalProcessorsDesc:  LogicalProcessorsDesc,
+		PhysicalProcessorsDesc: PhysicalProcessorsDesc,
+	}, nil
+}
+
+func (w wmiCpuloadCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	tables := parseWmiTables(unparsedStats)
+
+	for _, m := range []struct {
+		table, column string
+		desc          *prometheus.Desc
+	}{
+		{"system_perf", "ProcessorQueueLength", w.QueueLengthDesc},
+		{"computer_system", "NumberOfLogicalProcessors", w.LogicalProcessorsDesc},
+		{"computer_system", "NumberOfProcessors", w.PhysicalProcessorsDesc},
+	} {
+		value, err := strconv.ParseFloat(tables[m.table][m.column], 64)
+		if err != nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(m.desc, prometheus.GaugeValue, value)
+	}
+	return nil
+}
+
+// parseWmiTables handles WMI query results, a ',' separated header and row
+// per '[table]':
+//
+//	[system_perf]
+//	Name,ProcessorQueueLength,Timestamp_PerfTime
+//	,3,2560939628
+func parseWmiTables(unparsedStats *[]string) map[string]map[string]string {
+	tables := make(map[string]map[string]string)
+	var table string
+	var columns []string
+
+	for _, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		if match := wmiSubsection.FindStringSubmatch(stat); match != nil {
+			table, columns = match[1], nil
+			continue
+		}
+		fields := strings.Split(stat, ",")
+		if columns == nil {
+			columns = fields
+			continue
+		}
+		if _, ok := tables[table]; ok {
+			continue
+		}
+		tables[table] = make(map[string]string)
+		for i, column := range columns {
+			if i < len(fields) {
+				tables[table][column] = fields[i]
+			}
+		}
+	}
+	return tables
+}
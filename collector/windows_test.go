@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/bverschueren/check_mk_exporter/config"
+)
+
+func TestWindowsCollectorDispatch(t *testing.T) {
+	structuredStats := readAgentOutput(t, "../testdata/windows/agent_output")
+	if want, got := "windows", agentOS(structuredStats); want != got {
+		t.Fatalf("want agent OS %s, got %s", want, got)
+	}
+
+	mc, _ := NewMKCheckCollector(config.Target{})
+	df, ok := mc.collectorsFor("windows")["df"].(dfCollector)
+	if !ok || df.format != "windows" {
+		t.Errorf("want the Windows df collector for Windows agents")
+	}
+	if _, ok := mc.collectorsFor("linux")["winperf_processor"]; ok {
+		t.Errorf("want no Windows collectors for Linux agents")
+	}
+
+	stats := df.parseStats((*structuredStats)["df"])
+	if want, got := 2, len(stats); want != got {
+		t.Fatalf("want %d filesystems, got %d", want, got)
+	}
+	if want, got := (filesystemLabels{"Data Volume", "D:\\", "NTFS"}), stats[1].labels; want != got {
+		t.Errorf("want labels %+v, got %+v", want, got)
+	}
+}
+
+func TestParseWinperf(t *testing.T) {
+	structuredStats := readAgentOutput(t, "../testdata/windows/agent_output")
+
+	instances, counters := parseWinperf((*structuredStats)["winperf_if"])
+	if want, got := 2, len(instances); want != got {
+		t.Fatalf("want %d instances, got %d", want, got)
+	}
+	if want, got := 40712922.0, counters["-246"][0]; want != got {
+		t.Errorf("want %f bytes received, got %f", want, got)
+	}
+
+	metrics, err := collectMetrics(mustCollector(t, NewWinperfProcessorCollector), (*structuredStats)["winperf_processor"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 modes for the 2 processors, without _Total
+	if want, got := 6, len(metrics); want != got {
+		t.Errorf("want %d processor metrics, got %d", want, got)
+	}
+}
+
+func TestWindowsSections(t *testing.T) {
+	structuredStats := readAgentOutput(t, "../testdata/windows/agent_output")
+
+	for section, c := range map[string]struct {
+		factory func() (Collector, error)
+		want    int
+	}{
+		"mem":         {NewWindowsMemCollector, 8},
+		"services":    {NewWindowsServicesCollector, 3 * len(windowsServiceStates)},
+		"wmi_cpuload": {NewWmiCpuloadCollector, 3},
+	} {
+		metrics, err := collectMetrics(mustCollector(t, c.factory), (*structuredStats)[section])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(metrics); c.want != got {
+			t.Errorf("want %d metrics for %s, got %d", c.want, section, got)
+		}
+	}
+
+	tables := parseWmiTables((*structuredStats)["wmi_cpuload"])
+	if want, got := "3", tables["system_perf"]["ProcessorQueueLength"]; want != got {
+		t.Errorf("want processor queue length %s, got %s", want, got)
+	}
+}
+
+func mustCollector(t *testing.T, factory func() (Collector, error)) Collector {
+	c, err := factory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
@@ -0,0 +1,163 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+var (
+	winperfProcessorLabelNames = []string{"cpu", "mode"}
+	winperfPhydiskLabelNames   = []string{"disk"}
+	winperfIfLabelNames        = []string{"interface"}
+
+	// counter offsets of the processor object, in 100ns ticks
+	winperfProcessorModes = map[string]string{
+		"-232": "idle",
+		"-96":  "user",
+		"-94":  "privileged",
+	}
+)
+
+// winperfCollector handles the Windows agent's performance counter sections.
+type winperfCollector struct {
+	section       string
+	descs         map[string]*prometheus.Desc
+	ProcessorDesc *prometheus.Desc
+}
+
+type winperfCounter struct {
+	name      string
+	valueType prometheus.ValueType
+}
+
+// counter offsets exported per section
+var winperfCounters = map[string]map[string]winperfCounter{
+	"winperf_phydisk": {
+		"-14": {"read_bytes_total", prometheus.CounterValue},
+		"-12": {"written_bytes_total", prometheus.CounterValue},
+		"-20": {"reads_total", prometheus.CounterValue},
+		"-18": {"writes_total", prometheus.CounterValue},
+		"-36": {"queue_length", prometheus.GaugeValue},
+	},
+	"winperf_if": {
+		"10":   {"speed_bits_per_second", prometheus.GaugeValue},
+		"-246": {"receive_bytes_total", prometheus.CounterValue},
+		"14":   {"receive_unicast_packets_total", prometheus.CounterValue},
+		"16":   {"receive_non_unicast_packets_total", prometheus.CounterValue},
+		"18":   {"receive_discards_total", prometheus.CounterValue},
+		"20":   {"receive_errors_total", prometheus.CounterValue},
+		"-4":   {"transmit_bytes_total", prometheus.CounterValue},
+		"26":   {"transmit_unicast_packets_total", prometheus.CounterValue},
+		"28":   {"transmit_non_unicast_packets_total", prometheus.CounterValue},
+		"30":   {"transmit_discards_total", prometheus.CounterValue},
+		"32":   {"transmit_errors_total", prometheus.CounterValue},
+	},
+}
+
+func init() {
+	registerOSCollector("windows", "winperf_processor", NewWinperfProcessorCollector)
+	registerOSCollector("windows", "winperf_phydisk", NewWinperfPhydiskCollector)
+	registerOSCollector("windows", "winperf_if", NewWinperfIfCollector)
+}
+
+func NewWinperfProcessorCollector() (Collector, error) {
+	return winperfCollector{
+		section: "winperf_processor",
+		ProcessorDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "winperf_processor", "seconds_total"),
+			"Time the processors spent in each mode",
+			winperfProcessorLabelNames, nil,
+		),
+	}, nil
+}
+
+func NewWinperfPhydiskCollector() (Collector, error) {
+	return newWinperfCollector("winperf_phydisk", winperfPhydiskLabelNames), nil
+}
+
+func NewWinperfIfCollector() (Collector, error) {
+	return newWinperfCollector("winperf_if", winperfIfLabelNames), nil
+}
+
+func newWinperfCollector(section string, labelNames []string) winperfCollector {
+	descs := make(map[string]*prometheus.Desc)
+	for offset, counter := range winperfCounters[section] {
+		descs[offset] = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, section, counter.name),
+			"Performance counter "+offset+" of the "+strings.TrimPrefix(section, "winperf_")+" object",
+			labelNames, nil,
+		)
+	}
+	return winperfCollector{
+		section: section,
+		descs:   descs,
+	}
+}
+
+func (w winperfCollector) Update(unparsedStats *[]string, ch chan<- prometheus.Metric) error {
+	instances, counters := parseWinperf(unparsedStats)
+
+	for i, instance := range instances {
+		// the sum of all instances
+		if instance == "_Total" {
+			continue
+		}
+		if w.section == "winperf_processor" {
+			for offset, mode := range winperfProcessorModes {
+				values, ok := counters[offset]
+				if !ok {
+					continue
+				}
+				ch <- prometheus.MustNewConstMetric(
+					w.ProcessorDesc, prometheus.CounterValue, values[i]/1e7, instance, mode,
+				)
+			}
+			continue
+		}
+		for offset, counter := range winperfCounters[w.section] {
+			values, ok := counters[offset]
+			if !ok {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(
+				w.descs[offset], counter.valueType, values[i], instance,
+			)
+		}
+	}
+	return nil
+}
+
+// parseWinperf handles the raw performance counters of an object, with a
+// column per instance after the counter's offset and before its type:
+//
+//	1385714515.93 238 2156923
+//	3 instances: 0 1 _Total
+//	-232 21991925375 22154459843 44146385218 100nsec_timer_inv
+//	-96 103546384 97122281 200668665 100nsec_timer
+func parseWinperf(unparsedStats *[]string) ([]string, map[string][]float64) {
+	var instances []string
+	counters := make(map[string][]float64)
+
+	for i, stat := range *unparsedStats {
+		log.Tracef("[raw-structured] %s", stat)
+		fields := strings.Fields(stat)
+		if i == 0 || len(fields) < 2 {
+			continue
+		}
+		if fields[1] == "instances:" {
+			instances = fields[2:]
+			continue
+		}
+		if instances == nil || len(fields) < len(instances)+1 {
+			continue
+		}
+		values := make([]float64, len(instances))
+		for j := range instances {
+			values[j], _ = strconv.ParseFloat(fields[j+1], 64)
+		}
+		counters[fields[0]] = values
+	}
+	return instances, counters
+}
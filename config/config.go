@@ -4,50 +4,216 @@ import (
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 	"io/ioutil"
+	"strings"
+	"time"
 )
 
 type Target struct {
+	// Name is the key of the target in the config file
+	Name         string `yaml:"-"`
 	HostName     string `yaml:"HostName"`
 	Port         int    `yaml:"Port"`
 	User         string `yaml:"User"`
 	IdentityFile string `yaml:"IdentityFile"`
+	// Passphrase decrypts the output of agents with encryption enabled,
+	// alternatively read from PassphraseFile
+	Passphrase     string `yaml:"Passphrase"`
+	PassphraseFile string `yaml:"PassphraseFile"`
+	// Transport is how the agent output is fetched: 'ssh' (default), 'tls'
+	// for agents registered with the Checkmk agent controller, 'exec' to run
+	// the agent on the exporter's host, 'file' to read its spooled output or
+	// 'push' for output posted to the exporter
+	Transport string `yaml:"Transport"`
+	// the CA verifying the agent and the client certificate of the 'tls'
+	// transport, defaulting to the site-wide TLS config
+	TLSCAFile   string `yaml:"TLSCAFile"`
+	TLSCertFile string `yaml:"TLSCertFile"`
+	TLSKeyFile  string `yaml:"TLSKeyFile"`
+	// TLSServerName is the name in the agent's certificate, i.e. the UUID it
+	// was registered with, defaulting to HostName
+	TLSServerName string `yaml:"TLSServerName"`
+	// Command runs the agent with the 'ssh' and 'exec' transports, using
+	// sudo if set. Env is added to its environment, as are the Sections and
+	// Plugins to be run by wrappers restricting the agent's output.
+	Command  string            `yaml:"Command"`
+	Sudo     bool              `yaml:"Sudo"`
+	Env      map[string]string `yaml:"Env"`
+	Sections []string          `yaml:"Sections"`
+	Plugins  []string          `yaml:"Plugins"`
+	// Timeout kills the agent run by the 'exec' transport
+	Timeout time.Duration `yaml:"Timeout"`
+	// ParsePartialOutput parses the output of agent runs exiting non-zero,
+	// which is discarded by default
+	ParsePartialOutput bool `yaml:"ParsePartialOutput"`
+	// PollInterval polls the target in the background, serving scrapes from
+	// the results of the last poll
+	PollInterval time.Duration `yaml:"PollInterval"`
+	// SectionIntervals keeps the sections for their interval when a run of
+	// the agent omits them, as for sections with a cached(...) header
+	SectionIntervals map[string]time.Duration `yaml:"SectionIntervals"`
+	// SpoolDirectory holds the output of the 'file' and 'push' transports as
+	// a file named after the target, which is refused when older than MaxAge
+	SpoolDirectory string        `yaml:"SpoolDirectory"`
+	MaxAge         time.Duration `yaml:"MaxAge"`
+	// PushToken authenticates the output the target pushes, unless it uses
+	// a client certificate issued for its name
+	PushToken string `yaml:"PushToken"`
+}
+
+const (
+	defaultCommand = "check_mk_agent"
+	sshPort        = 22
+	// the port of the Checkmk agent, with or without the agent controller
+	agentPort = 6556
+)
+
+// LogwatchRule reclassifies logwatch lines of the logfiles matching Logfile
+// whose text matches Pattern, like Checkmk's logwatch patterns. Level is one
+// of C(ritical), W(arning), O(k) or I(gnore).
+type LogwatchRule struct {
+	Logfile string `yaml:"logfile"`
+	Pattern string `yaml:"pattern"`
+	Level   string `yaml:"level"`
+}
+
+type LogwatchConfig struct {
+	Rules []LogwatchRule `yaml:"rules"`
+}
+
+// FileinfoGroup aggregates the fileinfo entries whose path matches any of
+// the Include glob patterns and none of the Exclude ones.
+type FileinfoGroup struct {
+	Name    string   `yaml:"name"`
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+}
+
+type FileinfoConfig struct {
+	Groups []FileinfoGroup `yaml:"groups"`
+}
+
+// HostLabelsConfig controls what happens with the host labels of Checkmk 2.x
+// agents. Mode is one of 'info' (a single check_mk_host_labels series),
+// 'attach' (added to every metric of the scrape) or 'none'.
+type HostLabelsConfig struct {
+	Mode string `yaml:"mode"`
+}
+
+// TLSConfig holds the certificates of the 'tls' transport shared by all
+// targets of a site, i.e. the CA the agents' certificates are signed by and
+// the client certificate they were registered with.
+type TLSConfig struct {
+	CAFile   string `yaml:"ca_file"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// SpoolConfig holds the defaults of the 'file' and 'push' transports for all
+// targets.
+type SpoolConfig struct {
+	Directory string        `yaml:"directory"`
+	MaxAge    time.Duration `yaml:"max_age"`
+}
+
+// PollingConfig holds the default poll interval of the targets, and bounds
+// the number of concurrent polls and the random delay spreading them.
+type PollingConfig struct {
+	Interval    time.Duration `yaml:"interval"`
+	Concurrency int           `yaml:"concurrency"`
+	Jitter      time.Duration `yaml:"jitter"`
 }
 
 type Config struct {
-	Filename *string
+	Filename   *string
+	Logwatch   LogwatchConfig
+	Fileinfo   FileinfoConfig
+	HostLabels HostLabelsConfig
+	TLS        TLSConfig
+	Spool      SpoolConfig
+	Polling    PollingConfig
 }
 
-func (c Config) ReadFile(targets *map[string]Target) {
+func (c *Config) ReadFile(targets *map[string]Target) {
 	source, err := ioutil.ReadFile(*c.Filename)
 	if err != nil {
 		log.Fatalf("Unable to open '%s': %s", *c.Filename, err)
 	}
-	// read from 'targets' root element
+	// read from 'targets' root element, collector settings from their own
 	targetlist := struct {
-		List *map[string]Target `yaml:"targets"`
+		List       *map[string]Target `yaml:"targets"`
+		Logwatch   *LogwatchConfig    `yaml:"logwatch"`
+		Fileinfo   *FileinfoConfig    `yaml:"fileinfo"`
+		HostLabels *HostLabelsConfig  `yaml:"host_labels"`
+		TLS        *TLSConfig         `yaml:"tls"`
+		Spool      *SpoolConfig       `yaml:"spool"`
+		Polling    *PollingConfig     `yaml:"polling"`
 	}{
 		targets,
-	}
-	if err != nil {
-		panic(err)
+		&c.Logwatch,
+		&c.Fileinfo,
+		&c.HostLabels,
+		&c.TLS,
+		&c.Spool,
+		&c.Polling,
 	}
 	err = yaml.Unmarshal(source, &targetlist)
 	if err != nil {
 		log.Fatalf("error: %v", err)
 	}
+	for name, target := range *targets {
+		target.Name = name
+		if target.TLSCAFile == "" {
+			target.TLSCAFile = c.TLS.CAFile
+		}
+		if target.TLSCertFile == "" {
+			target.TLSCertFile = c.TLS.CertFile
+		}
+		if target.TLSKeyFile == "" {
+			target.TLSKeyFile = c.TLS.KeyFile
+		}
+		if target.SpoolDirectory == "" {
+			target.SpoolDirectory = c.Spool.Directory
+		}
+		if target.MaxAge == 0 {
+			target.MaxAge = c.Spool.MaxAge
+		}
+		if target.PollInterval == 0 {
+			target.PollInterval = c.Polling.Interval
+		}
+		(*targets)[name] = target
+	}
 	log.Debugf("targets: %+v", targetlist.List)
 }
 
 func (t *Target) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	type rawTarget Target
 	raw := rawTarget{
-		Port:         22,
 		IdentityFile: "~/.ssh/id_rsa",
+		Command:      defaultCommand,
 	}
 	if err := unmarshal(&raw); err != nil {
 		return err
 	}
+	if raw.Port == 0 {
+		raw.Port = sshPort
+		if raw.Transport == "tls" {
+			raw.Port = agentPort
+		}
+	}
 
 	*t = Target(raw)
 	return nil
 }
+
+// ReadPassphrase returns the passphrase of the target's agent encryption,
+// empty if the agent isn't expected to encrypt its output.
+func (t Target) ReadPassphrase() (string, error) {
+	if t.Passphrase != "" || t.PassphraseFile == "" {
+		return t.Passphrase, nil
+	}
+	passphrase, err := ioutil.ReadFile(t.PassphraseFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(passphrase), "\r\n"), nil
+}